@@ -5,10 +5,12 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"flag"
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/charmbracelet/ssh"
@@ -16,67 +18,101 @@ import (
 	"github.com/charmbracelet/wish/bubbletea"
 	"github.com/charmbracelet/wish/logging"
 	"github.com/charmbracelet/wish/scp"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/muesli/termenv"
 	gossh "golang.org/x/crypto/ssh"
 
+	"ctfsh/internal/command"
 	"ctfsh/internal/config"
 	"ctfsh/internal/db"
 	"ctfsh/internal/download"
 	"ctfsh/internal/instance"
+	"ctfsh/internal/reload"
 	"ctfsh/internal/ui"
 )
 
 func main() {
+	configPath := flag.String("config", "", fmt.Sprintf("path to the config file (env CTFSH_CONFIG, default %s)", config.DefaultPath))
+	applyFlags := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal("Failed to load config: ", err)
+	}
+	if err := applyFlags(cfg); err != nil {
+		log.Fatal("Invalid flag: ", err)
+	}
+	// Migrating only needs the database, not a complete setup
+	switch flag.Arg(0) {
+	case "":
+	case "migrate":
+		migrate(cfg)
+		return
+	default:
+		log.Fatalf("Unknown command %q, the only command is migrate", flag.Arg(0))
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("Invalid config: ", err)
+	}
+	if err := instance.Init(cfg); err != nil {
+		log.Fatal("Failed to initialize instancer: ", err)
+	}
+
 	log.Println("Starting CTF SSH server...")
-	if err := db.Init(); err != nil {
+	if err := db.Init(cfg); err != nil {
 		log.Fatal("Failed to initialize database:", err)
 	}
 	defer db.Close()
 
 	log.Println("Preparing challenge downloads...")
 	challenges := db.GetChallenges()
-	if err := download.PrepareChallengeFS(challenges); err != nil {
+	if err := download.PrepareChallengeFS(cfg, challenges); err != nil {
 		log.Fatal("Failed to prepare challenge FS: ", err)
 	}
 
 	log.Println("Building challenge images...")
 	wg := &sync.WaitGroup{}
+	mu := &sync.Mutex{}
+	var failed []string
 	for _, ch := range challenges {
 		if len(ch.Ports) > 0 {
 			wg.Add(1)
 			go func() {
-				path, err := filepath.Abs(config.ChallengeDir + "/" + ch.Name)
-				if err != nil {
-					log.Printf("Failed to get absolute path for challenge %s: %v", ch.Name, err)
-					wg.Done()
-					return
+				defer wg.Done()
+				if err := instance.CreateChallengeImage(ch); err != nil {
+					log.Printf("Failed to build image for challenge %s: %v", ch.Name, err)
+					mu.Lock()
+					failed = append(failed, ch.Name)
+					mu.Unlock()
 				}
-				instance.CreateChallengeImage(ch.Name, path)
-				wg.Done()
 			}()
 		}
 	}
 	wg.Wait()
-	log.Println("All challenges ready.")
-
-	handler := scp.NewFileSystemHandler(config.DownloadRoot)
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		log.Printf("%d challenge image(s) failed to build, their instances will retry on request: %s", len(failed), strings.Join(failed, ", "))
+	} else {
+		log.Println("All challenges ready.")
+	}
+	reload.Start(cfg)
 
-	if _, err := os.Stat(config.HostKeyPath); os.IsNotExist(err) {
+	if _, err := os.Stat(cfg.HostKeyPath); os.IsNotExist(err) {
 		key, err := rsa.GenerateKey(rand.Reader, 2048)
 		if err != nil {
 			log.Fatal("Failed to generate host key:", err)
 		}
 		keyBytes := x509.MarshalPKCS1PrivateKey(key)
 		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: keyBytes})
-		if err := os.WriteFile(config.HostKeyPath, keyPEM, 0600); err != nil {
+		if err := os.WriteFile(cfg.HostKeyPath, keyPEM, 0600); err != nil {
 			log.Fatal("Failed to write host key:", err)
 		}
 		log.Println("Generated new host key.")
 	}
 
 	s, err := wish.NewServer(
-		wish.WithAddress(fmt.Sprintf(":%d", config.Port)),
-		wish.WithHostKeyPath(config.HostKeyPath),
+		wish.WithAddress(fmt.Sprintf(":%d", cfg.Port)),
+		wish.WithHostKeyPath(cfg.HostKeyPath),
 		wish.WithPublicKeyAuth(func(ctx ssh.Context, key ssh.PublicKey) bool {
 			return true
 		}),
@@ -91,16 +127,39 @@ func main() {
 			}
 			return nil
 		},
-		wish.WithSubsystem("sftp", download.SftpSubsystem(config.DownloadRoot)),
+		wish.WithSubsystem("sftp", download.SftpSubsystem(cfg)),
+		// Middlewares run last to first: scp and exec commands are handled before falling through to the TUI
 		wish.WithMiddleware(
-			scp.Middleware(handler, handler),
-			bubbletea.Middleware(ui.TeaHandler),
+			bubbletea.MiddlewareWithProgramHandler(ui.NewProgramHandler(cfg), termenv.Ascii),
+			command.Middleware(cfg),
+			scp.Middleware(download.ScpHandler(cfg), nil),
 			logging.Middleware(),
 		),
 	)
 	if err != nil {
 		log.Fatal("Could not create server:", err)
 	}
-	log.Printf("CTF SSH server listening on %s:%d", config.Host, config.Port)
+	log.Printf("CTF SSH server listening on %s:%d", cfg.Host, cfg.Port)
 	log.Fatal(s.ListenAndServe())
 }
+
+// migrate brings the database schema up to date without starting the server
+func migrate(cfg *config.Config) {
+	if err := db.Open(cfg); err != nil {
+		log.Fatal("Failed to open database: ", err)
+	}
+	defer db.Close()
+	applied, err := db.Migrate()
+	if err != nil {
+		log.Fatal("Failed to migrate database: ", err)
+	}
+	version, err := db.SchemaVersion()
+	if err != nil {
+		log.Fatal("Failed to read schema version: ", err)
+	}
+	if len(applied) == 0 {
+		log.Printf("Database is up to date at schema version %d", version)
+	} else {
+		log.Printf("Migrated database to schema version %d", version)
+	}
+}
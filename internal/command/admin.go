@@ -0,0 +1,73 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"ctfsh/internal/db"
+	"ctfsh/internal/events"
+	"ctfsh/internal/reload"
+)
+
+// adminOnly wraps a command so only admins can run it
+func adminOnly(run func(r *request) error) func(r *request) error {
+	return func(r *request) error {
+		if !r.user.IsAdmin() {
+			return errors.New("this command is for admins only")
+		}
+		return run(r)
+	}
+}
+
+func runReveal(r *request) error {
+	if len(r.args) != 0 {
+		return usageError{handlers["reveal"].usage}
+	}
+	if !db.ScoreboardFrozen() {
+		return errors.New("the scoreboard is not frozen")
+	}
+	if err := db.RevealScoreboard(); err != nil {
+		return err
+	}
+	return r.output(struct {
+		Revealed bool `json:"revealed"`
+	}{true}, func(w io.Writer) {
+		fmt.Fprintln(w, "Scoreboard unfrozen, players now see the final standings.")
+	})
+}
+
+func runReload(r *request) error {
+	if len(r.args) != 0 {
+		return usageError{handlers["reload"].usage}
+	}
+	changed, err := reload.Reload(r.cfg)
+	if err != nil {
+		return err
+	}
+	return r.output(struct {
+		Changed []string `json:"changed"`
+	}{changed}, func(w io.Writer) {
+		if len(changed) == 0 {
+			fmt.Fprintln(w, "No challenges changed.")
+			return
+		}
+		fmt.Fprintf(w, "Reloaded %s\n", strings.Join(changed, ", "))
+	})
+}
+
+func runAnnounce(r *request) error {
+	message := strings.TrimSpace(strings.Join(r.args, " "))
+	if message == "" {
+		return usageError{handlers["announce"].usage}
+	}
+	a, err := db.CreateAnnouncement(r.user.ID, message)
+	if err != nil {
+		return err
+	}
+	events.Publish(events.Announcement{Author: a.Author, Message: a.Message, Timestamp: a.Timestamp})
+	return r.output(newAnnouncementInfo(*a), func(w io.Writer) {
+		fmt.Fprintln(w, "Announcement sent.")
+	})
+}
@@ -0,0 +1,197 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+
+	"ctfsh/internal/config"
+	"ctfsh/internal/db"
+)
+
+// request holds everything a command needs to run for one session
+type request struct {
+	cfg  *config.Config
+	s    ssh.Session
+	user *db.User
+	args []string
+	json bool
+}
+
+type handler struct {
+	usage string
+	help  string
+	run   func(r *request) error
+}
+
+var handlers map[string]handler
+
+func init() {
+	handlers = map[string]handler{
+		"help":       {"help", "show this message", runHelp},
+		"whoami":     {"whoami", "show your user and team", runWhoami},
+		"challenges": {"challenges", "list all challenges", runChallenges},
+		"show":       {"show <challenge>", "show challenge details", runShow},
+		"submit":     {"submit <challenge> <flag>", "submit a flag", runSubmit},
+		"scoreboard": {"scoreboard", "show the scoreboard", runScoreboard},
+		"team":       {"team", "show your team and its members", runTeam},
+		"news":       {"news", "show announcements from the organizers", runNews},
+		"reveal":     {"reveal", "(admin) unfreeze the scoreboard and reveal the final standings", adminOnly(runReveal)},
+		"reload":     {"reload", "(admin) load challenge changes from disk", adminOnly(runReload)},
+		"announce":   {"announce <message>", "(admin) send an announcement to every player", adminOnly(runAnnounce)},
+	}
+}
+
+// usageError is returned when a command is called with the wrong arguments
+type usageError struct{ usage string }
+
+func (e usageError) Error() string {
+	return "usage: " + e.usage
+}
+
+// exitError makes a command exit with a non-zero status without printing anything more
+type exitError struct{ code int }
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// Middleware serves non-interactive sessions (e.g. `ssh ctf submit <chal> <flag>`)
+// and passes sessions without a command on to the next handler.
+func Middleware(cfg *config.Config) wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			if len(s.Command()) == 0 {
+				next(s)
+				return
+			}
+			_ = s.Exit(run(cfg, s))
+		}
+	}
+}
+
+func run(cfg *config.Config, s ssh.Session) int {
+	r := &request{cfg: cfg, s: s}
+	for _, arg := range s.Command() {
+		if arg == "--json" {
+			r.json = true
+			continue
+		}
+		r.args = append(r.args, arg)
+	}
+	if len(r.args) == 0 {
+		r.args = []string{"help"}
+	}
+
+	name := r.args[0]
+	h, ok := handlers[name]
+	if !ok {
+		wish.Errorf(s, "unknown command %q, run `help` for a list of commands\n", name)
+		return 2
+	}
+	r.args = r.args[1:]
+	if name == "help" {
+		if err := h.run(r); err != nil {
+			return 1
+		}
+		return 0
+	}
+
+	if s.PublicKey() == nil {
+		wish.Errorln(s, "No public key provided, to use CTFsh please first run `ssh-keygen` to generate a key pair and then try reconnecting.")
+		return 1
+	}
+	user, err := db.GetUserBySSHKey(string(s.PublicKey().Marshal()))
+	if err != nil {
+		wish.Errorf(s, "This key is not registered, connect once with `%s` to choose a username.\n", r.sshCommand(""))
+		return 1
+	}
+	if user.Banned {
+		wish.Errorln(s, "You have been banned from this CTF.")
+		return 1
+	}
+	r.user = user
+
+	if err := h.run(r); err != nil {
+		switch err := err.(type) {
+		case exitError:
+			return err.code
+		case usageError:
+			wish.Errorln(s, err.Error())
+			return 2
+		default:
+			log.Printf("Command %q failed for user '%s': %v", h.usage, user.Username, err)
+			wish.Errorln(s, "error:", err.Error())
+			return 1
+		}
+	}
+	return 0
+}
+
+// output writes v as JSON when --json was passed, otherwise it calls text with a tabwriter
+func (r *request) output(v any, text func(w io.Writer)) error {
+	if r.json {
+		enc := json.NewEncoder(r.s)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	tw := tabwriter.NewWriter(r.s, 0, 4, 2, ' ', 0)
+	text(tw)
+	return tw.Flush()
+}
+
+// sshCommand formats an ssh invocation reaching this server as user
+func (r *request) sshCommand(user string) string {
+	target := r.cfg.Host
+	if user != "" {
+		target = user + "@" + target
+	}
+	return "ssh " + r.portArg("-p") + target
+}
+
+// portArg returns the flag needed to reach a non-default port, "-p" for ssh and "-P" for scp
+func (r *request) portArg(flag string) string {
+	if r.cfg.Port == 22 {
+		return ""
+	}
+	return fmt.Sprintf("%s %d ", flag, r.cfg.Port)
+}
+
+func runHelp(r *request) error {
+	names := make([]string, 0, len(handlers))
+	for name := range handlers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	type commandInfo struct {
+		Usage string `json:"usage"`
+		Help  string `json:"help"`
+	}
+	var infos []commandInfo
+	for _, name := range names {
+		infos = append(infos, commandInfo{handlers[name].usage, handlers[name].help})
+	}
+	return r.output(infos, func(w io.Writer) {
+		fmt.Fprintf(w, "Usage: %s <command> [--json]\n\n", r.sshCommand(""))
+		for _, info := range infos {
+			fmt.Fprintf(w, "  %s\t%s\n", info.Usage, info.Help)
+		}
+	})
+}
+
+// lookupChallenge finds a released challenge by its name, ignoring case
+func lookupChallenge(name string) (db.Challenge, error) {
+	chal, ok := db.GetChallenges()[strings.ToLower(strings.TrimSpace(name))]
+	if !ok || !chal.Released() {
+		return db.Challenge{}, fmt.Errorf("challenge %q not found", name)
+	}
+	return chal, nil
+}
@@ -0,0 +1,290 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"ctfsh/internal/db"
+)
+
+type challengeInfo struct {
+	Name        string   `json:"name"`
+	Title       string   `json:"title"`
+	Category    string   `json:"category"`
+	Author      string   `json:"author,omitempty"`
+	Points      int      `json:"points"`
+	Solves      int      `json:"solves"`
+	Solved      bool     `json:"solved"`
+	Locked      bool     `json:"locked,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Downloads   []string `json:"downloads,omitempty"`
+	Ports       []int    `json:"ports,omitempty"`
+}
+
+func newChallengeInfo(chal db.Challenge, solved map[int]bool, progress *db.Progress) challengeInfo {
+	return challengeInfo{
+		Name:     chal.Name,
+		Title:    chal.Title,
+		Category: chal.Category,
+		Author:   chal.Author,
+		Points:   chal.Value,
+		Solves:   chal.Solves,
+		Solved:   solved[chal.ID],
+		Locked:   !progress.Unlocked(chal),
+	}
+}
+
+func runWhoami(r *request) error {
+	type whoami struct {
+		Username string `json:"username"`
+		Team     string `json:"team,omitempty"`
+	}
+	info := whoami{Username: r.user.Username}
+	if r.user.TeamID != nil {
+		name, err := db.GetTeamName(*r.user.TeamID)
+		if err != nil {
+			return err
+		}
+		info.Team = name
+	}
+	return r.output(info, func(w io.Writer) {
+		fmt.Fprintf(w, "User:\t%s\n", info.Username)
+		if info.Team != "" {
+			fmt.Fprintf(w, "Team:\t%s\n", info.Team)
+		} else {
+			fmt.Fprintf(w, "Team:\t(none)\n")
+		}
+	})
+}
+
+func runChallenges(r *request) error {
+	solved, err := db.GetSolvedChallenges(r.user)
+	if err != nil {
+		return err
+	}
+	progress, err := db.GetProgress(r.user)
+	if err != nil {
+		return err
+	}
+	var infos []challengeInfo
+	for _, chal := range db.GetChallenges() {
+		if !chal.Released() {
+			continue
+		}
+		infos = append(infos, newChallengeInfo(chal, solved, progress))
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Category != infos[j].Category {
+			return infos[i].Category < infos[j].Category
+		}
+		if infos[i].Points != infos[j].Points {
+			return infos[i].Points > infos[j].Points
+		}
+		return infos[i].Name < infos[j].Name
+	})
+	return r.output(infos, func(w io.Writer) {
+		fmt.Fprintln(w, "NAME\tCATEGORY\tPOINTS\tSOLVES\tSOLVED")
+		for _, info := range infos {
+			status := ""
+			if info.Solved {
+				status = "✓"
+			} else if info.Locked {
+				status = "locked"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", info.Name, info.Category, info.Points, info.Solves, status)
+		}
+	})
+}
+
+func runShow(r *request) error {
+	if len(r.args) != 1 {
+		return usageError{handlers["show"].usage}
+	}
+	chal, err := lookupChallenge(r.args[0])
+	if err != nil {
+		return err
+	}
+	solved, err := db.GetSolvedChallenges(r.user)
+	if err != nil {
+		return err
+	}
+	progress, err := db.GetProgress(r.user)
+	if err != nil {
+		return err
+	}
+	if !progress.Unlocked(chal) {
+		return fmt.Errorf("challenge %s is locked, it requires %s", chal.Name, strings.Join(progress.MissingRequirements(chal), ", "))
+	}
+	info := newChallengeInfo(chal, solved, progress)
+	info.Description = chal.Description
+	info.Downloads = chal.Downloads
+	info.Ports = chal.Ports
+
+	return r.output(info, func(w io.Writer) {
+		fmt.Fprintf(w, "Name:\t%s\n", info.Title)
+		if info.Author != "" {
+			fmt.Fprintf(w, "Author:\t%s\n", info.Author)
+		}
+		fmt.Fprintf(w, "Category:\t%s\n", info.Category)
+		fmt.Fprintf(w, "Points:\t%d\n", info.Points)
+		fmt.Fprintf(w, "Solves:\t%d\n", info.Solves)
+		fmt.Fprintf(w, "Solved:\t%t\n", info.Solved)
+		if len(info.Downloads) > 0 {
+			fmt.Fprintf(w, "Download:\tscp %s-r %s:%s .\n", r.portArg("-P"), r.cfg.Host, chal.Name)
+		}
+		if len(info.Ports) > 0 {
+			tunnelCmd := r.sshCommand(chal.Name)
+			for _, port := range info.Ports {
+				tunnelCmd += fmt.Sprintf(" -L %d:%s:%d", port, chal.Name, port)
+			}
+			fmt.Fprintf(w, "Instance:\t%s\n", tunnelCmd)
+		}
+		fmt.Fprintf(w, "\n%s\n", info.Description)
+	})
+}
+
+func runSubmit(r *request) error {
+	if len(r.args) < 2 {
+		return usageError{handlers["submit"].usage}
+	}
+	if err := db.CheckEventOpen(); err != nil {
+		return err
+	}
+	chal, err := lookupChallenge(r.args[0])
+	if err != nil {
+		return err
+	}
+	flag := strings.Join(r.args[1:], " ")
+
+	correct, err := db.SubmitFlag(r.user.ID, chal.ID, flag)
+	if err != nil {
+		return err
+	}
+
+	type result struct {
+		Challenge string `json:"challenge"`
+		Correct   bool   `json:"correct"`
+	}
+	if err := r.output(result{chal.Name, correct}, func(w io.Writer) {
+		if correct {
+			fmt.Fprintln(w, "Correct! Flag accepted.")
+		} else {
+			fmt.Fprintln(w, "Incorrect flag. Try again.")
+		}
+	}); err != nil {
+		return err
+	}
+	if !correct {
+		return exitError{1}
+	}
+	return nil
+}
+
+func runScoreboard(r *request) error {
+	getScoreboard := db.GetScoreboard
+	if r.user.IsAdmin() {
+		getScoreboard = db.GetLiveScoreboard
+	}
+	teams, err := getScoreboard()
+	if err != nil {
+		return err
+	}
+
+	type row struct {
+		Rank       int       `json:"rank"`
+		Name       string    `json:"name"`
+		Solo       bool      `json:"solo"`
+		Players    int       `json:"players"`
+		Score      int       `json:"score"`
+		LastChange time.Time `json:"last_change,omitzero"`
+	}
+	rows := make([]row, 0, len(teams))
+	for i, team := range teams {
+		rows = append(rows, row{i + 1, team.Name, team.ID < 0, team.PlayerCount, team.Score, team.LastChange})
+	}
+	frozen := db.ScoreboardFrozen()
+	return r.output(rows, func(w io.Writer) {
+		if frozen && r.user.IsAdmin() {
+			fmt.Fprintln(w, "Frozen for players, showing live standings")
+		} else if frozen {
+			fmt.Fprintf(w, "Scoreboard frozen since %s\n", r.cfg.FreezeAt.Format(time.RFC3339))
+		}
+		fmt.Fprintln(w, "RANK\tTEAM\tPLAYERS\tSCORE\tLAST CHANGE")
+		for _, row := range rows {
+			name := row.Name
+			if row.Solo {
+				name += " (solo)"
+			}
+			lastChange := "-"
+			if !row.LastChange.IsZero() {
+				lastChange = row.LastChange.Local().Format("Jan 2 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%s\n", row.Rank, name, row.Players, row.Score, lastChange)
+		}
+	})
+}
+
+func runTeam(r *request) error {
+	if r.user.TeamID == nil {
+		return fmt.Errorf("you are not on a team")
+	}
+	name, code, err := db.GetTeamNameAndCode(*r.user.TeamID)
+	if err != nil {
+		return err
+	}
+	members, err := db.GetTeamMembers(*r.user.TeamID)
+	if err != nil {
+		return err
+	}
+
+	type teamInfo struct {
+		Name     string   `json:"name"`
+		JoinCode string   `json:"join_code"`
+		Members  []string `json:"members"`
+	}
+	info := teamInfo{Name: name, JoinCode: code}
+	for _, member := range members {
+		info.Members = append(info.Members, member.Username)
+	}
+	return r.output(info, func(w io.Writer) {
+		fmt.Fprintf(w, "Team:\t%s\n", info.Name)
+		fmt.Fprintf(w, "Join:\t%s\n", r.sshCommand(info.JoinCode))
+		fmt.Fprintf(w, "Members:\t%s\n", strings.Join(info.Members, ", "))
+	})
+}
+
+type announcementInfo struct {
+	Author    string    `json:"author"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func newAnnouncementInfo(a db.Announcement) announcementInfo {
+	return announcementInfo{Author: a.Author, Message: a.Message, Timestamp: a.Timestamp}
+}
+
+func runNews(r *request) error {
+	if len(r.args) != 0 {
+		return usageError{handlers["news"].usage}
+	}
+	announcements, err := db.GetAnnouncements()
+	if err != nil {
+		return err
+	}
+	infos := make([]announcementInfo, 0, len(announcements))
+	for _, a := range announcements {
+		infos = append(infos, newAnnouncementInfo(a))
+	}
+	return r.output(infos, func(w io.Writer) {
+		if len(infos) == 0 {
+			fmt.Fprintln(w, "No announcements yet.")
+			return
+		}
+		for _, info := range infos {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", info.Timestamp.Local().Format("Jan 2 15:04"), info.Author, info.Message)
+		}
+	})
+}
@@ -1,14 +1,332 @@
 package config
 
-const (
-	Host = "dev"
-	Port = 2223
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
-	HostKeyPath = "./.host_key"
-	DBPath      = "./ctfsh.sqlite"
+	"gopkg.in/yaml.v3"
+)
 
-	ChallengeDir = "./chals"
-	DownloadRoot = "./downloads"
+// DefaultPath is the config file read when none is given with -config or CTFSH_CONFIG
+const DefaultPath = "./config.yml"
 
-	DefaultPoints = 500
-)
+type Config struct {
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+
+	HostKeyPath string `yaml:"host_key_path"`
+	DBPath      string `yaml:"db_path"`
+
+	ChallengeDir string `yaml:"challenge_dir"`
+	DownloadRoot string `yaml:"download_root"`
+	// WatchInterval is how often the challenge directory is checked for changes, 0 turns it off
+	WatchInterval time.Duration `yaml:"watch_interval"`
+
+	DefaultPoints int `yaml:"default_points"`
+	// BloodBonuses are extra points for the first, second and third team to solve a challenge
+	BloodBonuses []int `yaml:"blood_bonuses"`
+	// FlagSecret keys per-team flags, one is generated and kept in the database if unset
+	FlagSecret string `yaml:"flag_secret"`
+	// SubmitLimit is how many wrong flags a team may submit for a challenge within SubmitWindow
+	// before it is locked out of it for Lockout, twice as long each time after. 0 turns it off.
+	SubmitLimit  int           `yaml:"submit_limit"`
+	SubmitWindow time.Duration `yaml:"submit_window"`
+	Lockout      time.Duration `yaml:"lockout"`
+
+	// EventStart and EventEnd bound when challenges are visible and flags are accepted, zero means no limit
+	EventStart time.Time `yaml:"event_start"`
+	EventEnd   time.Time `yaml:"event_end"`
+	// FreezeAt hides solves made after it from players' scoreboard until an admin reveals it
+	FreezeAt time.Time `yaml:"freeze_at"`
+
+	// Admins are the SHA256 fingerprints of admin keys, as printed by ssh-keygen -lf
+	Admins []string `yaml:"admins"`
+
+	// InstanceBackend selects how challenge instances are run: incus, docker or local
+	InstanceBackend string `yaml:"instance_backend"`
+	DockerSocket    string `yaml:"docker_socket"`
+}
+
+// Default returns the settings used when nothing else is configured
+func Default() *Config {
+	return &Config{
+		Host: "dev",
+		Port: 2223,
+
+		HostKeyPath: "./.host_key",
+		DBPath:      "./ctfsh.sqlite",
+
+		ChallengeDir: "./chals",
+		DownloadRoot: "./downloads",
+
+		WatchInterval: 5 * time.Second,
+
+		DefaultPoints: 500,
+
+		SubmitLimit:  10,
+		SubmitWindow: time.Minute,
+		Lockout:      30 * time.Second,
+
+		InstanceBackend: "incus",
+		DockerSocket:    "/var/run/docker.sock",
+	}
+}
+
+// setting is a top-level option that can be overridden from the environment or the command line
+type setting struct {
+	name  string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{"host", "hostname shown to players in connection commands", stringSetter(func(c *Config) *string { return &c.Host })},
+	{"port", "port to listen on", intSetter(func(c *Config) *int { return &c.Port })},
+	{"host-key-path", "path to the SSH host key (generated if missing)", stringSetter(func(c *Config) *string { return &c.HostKeyPath })},
+	{"db-path", "path to the SQLite database, or a postgres:// URL", stringSetter(func(c *Config) *string { return &c.DBPath })},
+	{"challenge-dir", "directory containing challenge folders", stringSetter(func(c *Config) *string { return &c.ChallengeDir })},
+	{"download-root", "directory challenge downloads are staged in (wiped on startup)", stringSetter(func(c *Config) *string { return &c.DownloadRoot })},
+	{"watch-interval", "how often to check the challenge directory for changes, e.g. 5s (0 to disable)", durationSetter(func(c *Config) *time.Duration { return &c.WatchInterval })},
+	{"default-points", "points for challenges that do not set any", intSetter(func(c *Config) *int { return &c.DefaultPoints })},
+	{"blood-bonuses", "comma-separated bonus points for the first three solves of a challenge", intListSetter(func(c *Config) *[]int { return &c.BloodBonuses })},
+	{"flag-secret", "secret per-team flags are derived from (generated if empty)", stringSetter(func(c *Config) *string { return &c.FlagSecret })},
+	{"submit-limit", "wrong flags a team may submit per challenge within submit-window before a lockout (0 to disable)", intSetter(func(c *Config) *int { return &c.SubmitLimit })},
+	{"submit-window", "window wrong flags are counted in, e.g. 1m", durationSetter(func(c *Config) *time.Duration { return &c.SubmitWindow })},
+	{"lockout", "length of the first lockout, doubled for each one after it, e.g. 30s", durationSetter(func(c *Config) *time.Duration { return &c.Lockout })},
+	{"event-start", "when challenges open, as RFC 3339 (e.g. 2025-06-01T18:00:00Z)", timeSetter(func(c *Config) *time.Time { return &c.EventStart })},
+	{"event-end", "when flag submission closes, as RFC 3339", timeSetter(func(c *Config) *time.Time { return &c.EventEnd })},
+	{"freeze-at", "when the scoreboard freezes for players, as RFC 3339", timeSetter(func(c *Config) *time.Time { return &c.FreezeAt })},
+	{"admins", "comma-separated SHA256 fingerprints of admin keys", stringListSetter(func(c *Config) *[]string { return &c.Admins })},
+	{"instance-backend", "how challenge instances are run: incus, docker or local", stringSetter(func(c *Config) *string { return &c.InstanceBackend })},
+	{"docker-socket", "Docker Engine socket used by the docker instance backend", stringSetter(func(c *Config) *string { return &c.DockerSocket })},
+}
+
+func stringSetter(field func(c *Config) *string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func intSetter(field func(c *Config) *int) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("expected an integer, got %q", value)
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func intListSetter(field func(c *Config) *[]int) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		var list []int
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			n, err := strconv.Atoi(item)
+			if err != nil {
+				return fmt.Errorf("expected comma-separated integers, got %q", value)
+			}
+			list = append(list, n)
+		}
+		*field(c) = list
+		return nil
+	}
+}
+
+func stringListSetter(field func(c *Config) *[]string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*field(c) = list
+		return nil
+	}
+}
+
+func durationSetter(field func(c *Config) *time.Duration) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("expected a duration such as 5s, got %q", value)
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+func timeSetter(field func(c *Config) *time.Time) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		if value == "" {
+			*field(c) = time.Time{}
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return fmt.Errorf("expected an RFC 3339 time, got %q", value)
+		}
+		*field(c) = t
+		return nil
+	}
+}
+
+// envName maps a setting name to its environment variable, e.g. host-key-path -> CTFSH_HOST_KEY_PATH
+func envName(name string) string {
+	return "CTFSH_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// RegisterFlags adds a flag for every setting to fs. The returned function applies
+// the flags that were actually passed on top of an already loaded config.
+func RegisterFlags(fs *flag.FlagSet) func(c *Config) error {
+	values := make(map[string]*string)
+	for _, s := range settings {
+		values[s.name] = fs.String(s.name, "", fmt.Sprintf("%s (env %s)", s.usage, envName(s.name)))
+	}
+	return func(c *Config) error {
+		var err error
+		fs.Visit(func(f *flag.Flag) {
+			for _, s := range settings {
+				if s.name == f.Name && err == nil {
+					if setErr := s.set(c, *values[s.name]); setErr != nil {
+						err = fmt.Errorf("-%s: %w", s.name, setErr)
+					}
+				}
+			}
+		})
+		return err
+	}
+}
+
+// Load reads the config file at path on top of the defaults and then applies
+// environment overrides. A missing file is only an error if it was asked for explicitly.
+func Load(path string) (*Config, error) {
+	c := Default()
+
+	explicit := path != ""
+	if !explicit {
+		path = os.Getenv("CTFSH_CONFIG")
+		explicit = path != ""
+	}
+	if !explicit {
+		path = DefaultPath
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && (explicit || !errors.Is(err, os.ErrNotExist)) {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("parsing config %s: %w", path, err)
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(envName(s.name)); ok {
+			if err := s.set(c, value); err != nil {
+				return nil, fmt.Errorf("%s: %w", envName(s.name), err)
+			}
+		}
+	}
+	return c, nil
+}
+
+// Validate checks the config for values that would break the server at runtime
+func (c *Config) Validate() error {
+	if c.Host == "" {
+		return errors.New("host must not be empty")
+	}
+	if c.Port <= 0 || c.Port > 65535 {
+		return fmt.Errorf("port %d is out of range", c.Port)
+	}
+	if c.HostKeyPath == "" {
+		return errors.New("host_key_path must not be empty")
+	}
+	if c.DBPath == "" {
+		return errors.New("db_path must not be empty")
+	}
+	if c.WatchInterval < 0 {
+		return errors.New("watch_interval must not be negative")
+	}
+	if c.DefaultPoints <= 0 {
+		return errors.New("default_points must be positive")
+	}
+	if len(c.BloodBonuses) > 3 {
+		return errors.New("blood_bonuses takes at most three values")
+	}
+	for _, bonus := range c.BloodBonuses {
+		if bonus < 0 {
+			return errors.New("blood_bonuses must not be negative")
+		}
+	}
+	if c.SubmitLimit < 0 {
+		return errors.New("submit_limit must not be negative")
+	}
+	if c.SubmitLimit > 0 && (c.SubmitWindow <= 0 || c.Lockout <= 0) {
+		return errors.New("submit_window and lockout must be positive when submit_limit is set")
+	}
+	if !c.EventStart.IsZero() && !c.EventEnd.IsZero() && !c.EventEnd.After(c.EventStart) {
+		return errors.New("event_end must be after event_start")
+	}
+	for _, admin := range c.Admins {
+		if !strings.HasPrefix(admin, "SHA256:") {
+			return fmt.Errorf("admin %q is not a SHA256 key fingerprint", admin)
+		}
+	}
+	switch c.InstanceBackend {
+	case "incus", "local":
+	case "docker":
+		if c.DockerSocket == "" {
+			return errors.New("docker_socket must not be empty")
+		}
+	default:
+		return fmt.Errorf("instance_backend must be incus, docker or local, not %q", c.InstanceBackend)
+	}
+
+	info, err := os.Stat(c.ChallengeDir)
+	if err != nil {
+		return fmt.Errorf("challenge_dir: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("challenge_dir %s is not a directory", c.ChallengeDir)
+	}
+
+	// The download root is deleted and recreated on startup, so make sure it can't take anything else with it
+	if c.DownloadRoot == "" {
+		return errors.New("download_root must not be empty")
+	}
+	downloads, err := filepath.Abs(c.DownloadRoot)
+	if err != nil {
+		return fmt.Errorf("download_root: %w", err)
+	}
+	chals, err := filepath.Abs(c.ChallengeDir)
+	if err != nil {
+		return fmt.Errorf("challenge_dir: %w", err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if isWithin(cwd, downloads) || isWithin(chals, downloads) || isWithin(downloads, chals) {
+		return fmt.Errorf("download_root %s must be a dedicated directory", c.DownloadRoot)
+	}
+	return nil
+}
+
+// isWithin reports whether path is dir or inside it
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
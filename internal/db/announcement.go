@@ -0,0 +1,51 @@
+package db
+
+import "time"
+
+// Announcement is a message from the organizers to every player
+type Announcement struct {
+	ID        int
+	Author    string
+	Message   string
+	Timestamp time.Time
+}
+
+// CreateAnnouncement stores an announcement posted by an admin
+func CreateAnnouncement(userID int, message string) (*Announcement, error) {
+	id, err := db.Insert("INSERT INTO announcements (user_id, message) VALUES (?, ?)", userID, message)
+	if err != nil {
+		return nil, err
+	}
+	a := &Announcement{}
+	err = db.QueryRow(`
+		SELECT a.id, u.username, a.message, a.timestamp
+		FROM announcements a
+		JOIN users u ON a.user_id = u.id
+		WHERE a.id = ?
+	`, id).Scan(&a.ID, &a.Author, &a.Message, &a.Timestamp)
+	return a, err
+}
+
+// GetAnnouncements returns every announcement, newest first
+func GetAnnouncements() ([]Announcement, error) {
+	rows, err := db.Query(`
+		SELECT a.id, u.username, a.message, a.timestamp
+		FROM announcements a
+		JOIN users u ON a.user_id = u.id
+		ORDER BY a.timestamp DESC, a.id DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var announcements []Announcement
+	for rows.Next() {
+		var a Announcement
+		if err := rows.Scan(&a.ID, &a.Author, &a.Message, &a.Timestamp); err != nil {
+			return nil, err
+		}
+		announcements = append(announcements, a)
+	}
+	return announcements, rows.Err()
+}
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+
+	"ctfsh/internal/events"
+)
+
+// Award kinds
+const (
+	AwardBlood      = "blood"      // one of the first solves of a challenge
+	AwardAdjustment = "adjustment" // points added or taken away by an admin
+)
+
+// bloodPlaces is how many solves of each challenge count as bloods
+const bloodPlaces = 3
+
+// Blood is one of the first solves of a challenge
+type Blood struct {
+	Place    int
+	UserID   int
+	Username string
+	TeamID   *int
+	TeamName string
+	Points   int
+}
+
+// By reports whether the blood belongs to the user or their team
+func (b Blood) By(user *User) bool {
+	if b.TeamID != nil {
+		return user.TeamID != nil && *b.TeamID == *user.TeamID
+	}
+	return b.UserID == user.ID
+}
+
+// recordBlood awards a blood if the solve that was just submitted is among the first of the challenge,
+// returning its bonus points. It must only be called for a team (or solo player) that had not solved the challenge before.
+func recordBlood(submissionID int64, userID int, teamID *int, challengeID int) int {
+	// Only solves submitted up to this one count towards its place, so teams solving at the same time get different places
+	var place int
+	err := db.QueryRow(`
+		SELECT COUNT(DISTINCT CASE WHEN u.team_id IS NULL THEN -u.id ELSE u.team_id END)
+		FROM submissions s
+		JOIN users u ON s.user_id = u.id
+		WHERE s.challenge_id = ? AND s.correct AND s.id <= ?
+	`, challengeID, submissionID).Scan(&place)
+	if err != nil {
+		log.Printf("Failed to count solves for blood: %v\n", err)
+		return 0
+	}
+
+	for place <= bloodPlaces {
+		points := 0
+		if place <= len(cfg.BloodBonuses) {
+			points = cfg.BloodBonuses[place-1]
+		}
+		_, err = db.Exec("INSERT INTO awards (user_id, team_id, challenge_id, kind, place, points) VALUES (?, ?, ?, ?, ?, ?)",
+			userID, teamID, challengeID, AwardBlood, place, points)
+		if err == nil {
+			return points
+		}
+
+		// A solve submitted earlier but committed later took the place, so take the next free one
+		next := 0
+		if qerr := db.QueryRow("SELECT COALESCE(MAX(place), 0) + 1 FROM awards WHERE challenge_id = ? AND kind = ?", challengeID, AwardBlood).Scan(&next); qerr != nil || next <= place {
+			log.Printf("Failed to insert blood award: %v\n", err)
+			return 0
+		}
+		place = next
+	}
+	return 0
+}
+
+// GetChallengeBloods returns the bloods of every challenge by challenge ID, in place order,
+// leaving out those awarded while the scoreboard is frozen
+func GetChallengeBloods() (map[int][]Blood, error) {
+	rows, err := db.Query(`
+		SELECT a.challenge_id, a.place, a.user_id, u.username, a.team_id, COALESCE(t.name, ''), a.points
+		FROM awards a
+		JOIN users u ON a.user_id = u.id
+		LEFT JOIN teams t ON a.team_id = t.id
+		WHERE a.kind = ? AND a.timestamp < ?
+		ORDER BY a.challenge_id, a.place
+	`, AwardBlood, sqlTime(scoreboardCutoff()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bloods := make(map[int][]Blood)
+	for rows.Next() {
+		var challengeID int
+		var blood Blood
+		var teamID sql.NullInt64
+		if err := rows.Scan(&challengeID, &blood.Place, &blood.UserID, &blood.Username, &teamID, &blood.TeamName, &blood.Points); err != nil {
+			return nil, err
+		}
+		if teamID.Valid {
+			id := int(teamID.Int64)
+			blood.TeamID = &id
+		}
+		bloods[challengeID] = append(bloods[challengeID], blood)
+	}
+	return bloods, rows.Err()
+}
+
+// getAwardChanges returns the awards made before cutoff. Awards made to a team stay
+// with it, the rest follow the user to whatever team they are on now.
+func getAwardChanges(cutoff string) ([]scoreChange, error) {
+	return queryScoreChanges(`
+		SELECT COALESCE(a.team_id, u.team_id), a.user_id, a.points, a.timestamp
+		FROM awards a
+		LEFT JOIN users u ON a.user_id = u.id
+		WHERE a.points <> 0 AND a.timestamp < ?
+	`, cutoff)
+}
+
+// AdjustScore adds points (or takes them away, if negative) to a scoreboard entry:
+// a team, or a solo player by their negative ID as in GetScoreboard
+func AdjustScore(scoreboardID, points int, note string) error {
+	var err error
+	if scoreboardID < 0 {
+		_, err = db.Exec("INSERT INTO awards (user_id, kind, points, note) VALUES (?, ?, ?, ?)", -scoreboardID, AwardAdjustment, points, note)
+	} else {
+		_, err = db.Exec("INSERT INTO awards (team_id, kind, points, note) VALUES (?, ?, ?, ?)", scoreboardID, AwardAdjustment, points, note)
+	}
+	if err == nil {
+		invalidateScores()
+		events.Publish(events.ScoresChanged{})
+	}
+	return err
+}
+
+// Ordinal formats the place as 1st, 2nd or 3rd
+func (b Blood) Ordinal() string {
+	switch b.Place {
+	case 1:
+		return "1st"
+	case 2:
+		return "2nd"
+	case 3:
+		return "3rd"
+	}
+	return strconv.Itoa(b.Place) + "th"
+}
@@ -1,15 +1,17 @@
 package db
 
 import (
+	"database/sql"
 	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"gopkg.in/yaml.v3"
-
-	"ctfsh/internal/config"
 )
 
 type Challenge struct {
@@ -22,28 +24,65 @@ type Challenge struct {
 	Flag        string
 	Author      string
 	BuildDir    string
+	Command     string
 	Downloads   []string
 	Ports       []int
+	Flags       []ChallengeFlag // every accepted flag, Flag is the one handed to instances and downloads
+	Hints       []Hint
+	Requires    []Requirement
+	ReleaseAt   time.Time // hidden until then, zero to release with the event
+
+	// Dynamic scoring, Points is the initial value
+	DecayFunction string
+	MinimumPoints int
+	DecaySolves   int
+
+	Hidden   bool // its ctfsh.yml is gone, kept so solves still count
+	Disabled bool // hidden from players by an admin
+
+	Solves int // teams and solo players that solved it
+	Value  int // current value after decay
 }
 
+// generation counts the reloads that changed any challenge
+var generation atomic.Int64
+
 type challengeConfig struct {
 	Challenge struct {
-		Name        string   `yaml:"name"`
-		Author      string   `yaml:"author"`
-		Category    string   `yaml:"category"`
-		Description string   `yaml:"description"`
-		Flag        string   `yaml:"flag"`
-		Points      int      `yaml:"points"`
-		Downloads   []string `yaml:"downloads"`
+		Name        string    `yaml:"name"`
+		Author      string    `yaml:"author"`
+		Category    string    `yaml:"category"`
+		Description string    `yaml:"description"`
+		Flag        string    `yaml:"flag"`
+		Points      int       `yaml:"points"`
+		ReleaseAt   time.Time `yaml:"release_at"`
+		Downloads   []string  `yaml:"downloads"`
 		Instance    struct {
-			Build string `yaml:"build"`
-			Ports []int  `yaml:"ports"`
+			Build   string `yaml:"build"`
+			Command string `yaml:"command"`
+			Ports   []int  `yaml:"ports"`
 		} `yaml:"instance"`
+		Scoring struct {
+			Function string `yaml:"function"`
+			Minimum  int    `yaml:"minimum"`
+			Decay    int    `yaml:"decay"`
+		} `yaml:"scoring"`
+		Flags    []ChallengeFlag `yaml:"flags"`
+		Hints    []Hint          `yaml:"hints"`
+		Requires []Requirement   `yaml:"requires"`
 	} `yaml:"challenge"`
 }
 
-func LoadChallenges() {
-	filepath.WalkDir(config.ChallengeDir, func(path string, d fs.DirEntry, err error) error {
+// LoadChallenges brings the challenges table in line with the ctfsh.yml files in the challenge
+// directory: new challenges are added, changed ones updated and ones whose file is gone hidden.
+// It returns the names of the challenges that changed.
+func LoadChallenges() []string {
+	existing := getChallenges(true)
+	seen := make(map[string]bool)
+	complete := true // only hide missing challenges if every file could be read
+	var changed []string
+
+	err := filepath.WalkDir(cfg.ChallengeDir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -53,52 +92,223 @@ func LoadChallenges() {
 
 		name := strings.ToLower(d.Name())
 		if name == "ctfsh.yml" || name == "ctfsh.yaml" {
-			data, err := os.ReadFile(path)
+			chal, err := loadChallengeConfig(path)
 			if err != nil {
-				return err
-			}
-			chalConfig := challengeConfig{}
-			if err := yaml.Unmarshal(data, &chalConfig); err != nil {
-				return err
+				log.Printf("Failed to load challenge %s: %v\n", path, err)
+				complete = false
+				return nil
 			}
-			if chalConfig.Challenge.Points <= 0 {
-				chalConfig.Challenge.Points = config.DefaultPoints
+			if seen[chal.Name] {
+				log.Printf("Challenge %s is defined more than once, ignoring %s\n", chal.Name, path)
+				return nil
 			}
+			seen[chal.Name] = true
 
-			CreateChallenge(Challenge{
-				Name:        strings.ReplaceAll(strings.ToLower(strings.TrimSpace(chalConfig.Challenge.Name)), " ", "_"),
-				Title:       chalConfig.Challenge.Name,
-				Description: chalConfig.Challenge.Description,
-				Category:    chalConfig.Challenge.Category,
-				Points:      chalConfig.Challenge.Points,
-				Flag:        chalConfig.Challenge.Flag,
-				Author:      chalConfig.Challenge.Author,
-				Downloads:   chalConfig.Challenge.Downloads,
-				Ports:       chalConfig.Challenge.Instance.Ports,
-				BuildDir:    chalConfig.Challenge.Instance.Build,
-			})
+			old, ok := existing[chal.Name]
+			switch {
+			case !ok:
+				CreateChallenge(chal)
+			case old.Hidden || !sameChallenge(old, chal):
+				updateChallenge(old.ID, chal)
+			default:
+				return nil
+			}
+			changed = append(changed, chal.Name)
 		}
 		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to walk challenge directory: %v\n", err)
+		complete = false
+	}
+
+	if complete {
+		for name, chal := range existing {
+			if !seen[name] && !chal.Hidden {
+				if _, err := db.Exec("UPDATE challenges SET hidden = TRUE WHERE id = ?", chal.ID); err != nil {
+					log.Printf("Failed to hide challenge %s: %v\n", name, err)
+					continue
+				}
+				changed = append(changed, name)
+			}
+		}
+	}
+	if len(changed) > 0 {
+		generation.Add(1)
+		invalidateScores()
+	}
+	checkRequirements(GetChallenges())
+	return changed
+}
+
+// ChallengesGeneration changes whenever LoadChallenges changes any challenge, so
+// long-lived sessions can tell when to reload theirs
+func ChallengesGeneration() int64 {
+	return generation.Load()
+}
+
+// loadChallengeConfig reads a ctfsh.yml file into a challenge, filling in defaults
+func loadChallengeConfig(path string) (Challenge, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Challenge{}, err
+	}
+	chalConfig := challengeConfig{}
+	if err := yaml.Unmarshal(data, &chalConfig); err != nil {
+		return Challenge{}, err
+	}
+	if chalConfig.Challenge.Points <= 0 {
+		chalConfig.Challenge.Points = cfg.DefaultPoints
+	}
+	flag, flags := loadFlags(chalConfig.Challenge.Name, chalConfig.Challenge.Flag, chalConfig.Challenge.Flags)
+	if len(flags) == 0 {
+		log.Printf("Challenge %s has no flags\n", chalConfig.Challenge.Name)
+	}
+
+	scoring := &chalConfig.Challenge.Scoring
+	scoring.Function = strings.ToLower(strings.TrimSpace(scoring.Function))
+	if scoring.Function == "" {
+		scoring.Function = DecayStatic
+	}
+	if !validDecayFunction(scoring.Function) {
+		log.Printf("Challenge %s has unknown scoring function %q, using static points\n", chalConfig.Challenge.Name, scoring.Function)
+		scoring.Function = DecayStatic
+	}
+	if scoring.Function != DecayStatic && scoring.Decay <= 0 {
+		log.Printf("Challenge %s has %s scoring without a positive decay, using static points\n", chalConfig.Challenge.Name, scoring.Function)
+		scoring.Function = DecayStatic
+	}
+	scoring.Minimum = min(max(scoring.Minimum, 0), chalConfig.Challenge.Points)
+
+	requires := chalConfig.Challenge.Requires
+	for i := range requires {
+		if requires[i].Challenge != "" {
+			requires[i].Challenge = challengeName(requires[i].Challenge)
+		}
+	}
+
+	return Challenge{
+		Name:        challengeName(chalConfig.Challenge.Name),
+		Title:       chalConfig.Challenge.Name,
+		Description: chalConfig.Challenge.Description,
+		Category:    chalConfig.Challenge.Category,
+		Points:      chalConfig.Challenge.Points,
+		Flag:        flag,
+		Flags:       flags,
+		Hints:       chalConfig.Challenge.Hints,
+		Requires:    requires,
+		ReleaseAt:   chalConfig.Challenge.ReleaseAt,
+		Author:      chalConfig.Challenge.Author,
+		Downloads:   chalConfig.Challenge.Downloads,
+		Ports:       chalConfig.Challenge.Instance.Ports,
+		BuildDir:    chalConfig.Challenge.Instance.Build,
+		Command:     chalConfig.Challenge.Instance.Command,
+
+		DecayFunction: scoring.Function,
+		MinimumPoints: scoring.Minimum,
+		DecaySolves:   scoring.Decay,
+	}, nil
+}
 
+// sameChallenge reports whether a stored challenge already matches its definition on disk
+func sameChallenge(stored, loaded Challenge) bool {
+	hintsEqual := slices.EqualFunc(stored.Hints, loaded.Hints, func(a, b Hint) bool {
+		return a.Content == b.Content && a.Cost == max(b.Cost, 0)
 	})
+	flags := loaded.Flags
+	if len(flags) == 0 {
+		flags = []ChallengeFlag{{Type: FlagStatic, Flag: loaded.Flag}}
+	}
+	return stored.Title == loaded.Title &&
+		stored.Description == loaded.Description &&
+		stored.Category == loaded.Category &&
+		stored.Points == loaded.Points &&
+		stored.Flag == loaded.Flag &&
+		stored.Author == loaded.Author &&
+		stored.BuildDir == loaded.BuildDir &&
+		stored.Command == loaded.Command &&
+		stored.ReleaseAt.Equal(loaded.ReleaseAt) &&
+		stored.DecayFunction == loaded.DecayFunction &&
+		stored.MinimumPoints == loaded.MinimumPoints &&
+		stored.DecaySolves == loaded.DecaySolves &&
+		slices.Equal(stored.Downloads, loaded.Downloads) &&
+		slices.Equal(stored.Ports, loaded.Ports) &&
+		slices.EqualFunc(stored.Flags, flags, func(a, b ChallengeFlag) bool {
+			return a.Type == b.Type && a.Flag == b.Flag
+		}) &&
+		slices.Equal(stored.Requires, loaded.Requires) &&
+		hintsEqual
+}
+
+// challengeName turns a challenge title into the name used for downloads and instances
+func challengeName(title string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(title)), " ", "_")
 }
 
 func CreateChallenge(chal Challenge) {
-	result, err := db.Exec("INSERT INTO challenges (name, title, description, category, points, flag, author, build_dir) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
-		chal.Name, chal.Title, chal.Description, chal.Category, chal.Points, chal.Flag, chal.Author, chal.BuildDir)
+	tx, err := db.Begin()
 	if err != nil {
 		log.Printf("Failed to insert challenge: %v\n", err)
 		return
 	}
-	id, err := result.LastInsertId()
+	defer tx.Rollback() // Rollback on error
+
+	id, err := tx.Insert("INSERT INTO challenges (name, title, description, category, points, flag, author, build_dir, command, decay_function, minimum_points, decay_solves, release_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		chal.Name, chal.Title, chal.Description, chal.Category, chal.Points, chal.Flag, chal.Author, chal.BuildDir, chal.Command, chal.DecayFunction, chal.MinimumPoints, chal.DecaySolves, nullTime(chal.ReleaseAt))
 	if err != nil {
-		log.Printf("Failed to get last insert ID: %v\n", err)
+		log.Printf("Failed to insert challenge: %v\n", err)
 		return
 	}
 	chal.ID = int(id)
+	createHints(tx, chal.ID, chal.Hints)
+	createChallengeDetails(tx, chal)
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to insert challenge %s: %v\n", chal.Name, err)
+	}
+}
+
+// updateChallenge overwrites a stored challenge with its new definition and makes it visible again.
+// It happens in one transaction, so flags being submitted never see the challenge half updated.
+func updateChallenge(id int, chal Challenge) {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("Failed to update challenge %s: %v\n", chal.Name, err)
+		return
+	}
+	defer tx.Rollback() // Rollback on error
+
+	_, err = tx.Exec("UPDATE challenges SET title = ?, description = ?, category = ?, points = ?, flag = ?, author = ?, build_dir = ?, command = ?, decay_function = ?, minimum_points = ?, decay_solves = ?, release_at = ?, hidden = FALSE WHERE id = ?",
+		chal.Title, chal.Description, chal.Category, chal.Points, chal.Flag, chal.Author, chal.BuildDir, chal.Command, chal.DecayFunction, chal.MinimumPoints, chal.DecaySolves, nullTime(chal.ReleaseAt), id)
+	if err != nil {
+		log.Printf("Failed to update challenge %s: %v\n", chal.Name, err)
+		return
+	}
+	for _, table := range []string{"challenge_flags", "challenge_requirements", "challenge_downloads", "challenge_ports"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE challenge_id = ?", id); err != nil {
+			log.Printf("Failed to clear %s of challenge %s: %v\n", table, chal.Name, err)
+			return
+		}
+	}
+	chal.ID = id
+	updateHints(tx, chal.ID, chal.Hints)
+	createChallengeDetails(tx, chal)
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to update challenge %s: %v\n", chal.Name, err)
+	}
+}
+
+// createChallengeDetails stores the flags, requirements, downloads and ports of a challenge
+func createChallengeDetails(q execer, chal Challenge) {
+	createRequirements(q, chal.ID, chal.Requires)
+	for _, flag := range chal.Flags {
+		_, err := q.Exec("INSERT INTO challenge_flags (challenge_id, type, flag) VALUES (?, ?, ?)", chal.ID, flag.Type, flag.Flag)
+		if err != nil {
+			log.Printf("Failed to insert challenge flag: %v\n", err)
+		}
+	}
 	if len(chal.Downloads) > 0 {
 		for _, download := range chal.Downloads {
-			_, err := db.Exec("INSERT INTO challenge_downloads (path, challenge_id) VALUES (?, ?)", download, chal.ID)
+			_, err := q.Exec("INSERT INTO challenge_downloads (path, challenge_id) VALUES (?, ?)", download, chal.ID)
 			if err != nil {
 				log.Printf("Failed to insert challenge download: %v\n", err)
 			}
@@ -106,7 +316,7 @@ func CreateChallenge(chal Challenge) {
 	}
 	if len(chal.Ports) > 0 {
 		for _, port := range chal.Ports {
-			_, err := db.Exec("INSERT INTO challenge_ports (port, challenge_id) VALUES (?, ?)", port, chal.ID)
+			_, err := q.Exec("INSERT INTO challenge_ports (port, challenge_id) VALUES (?, ?)", port, chal.ID)
 			if err != nil {
 				log.Printf("Failed to insert challenge port: %v\n", err)
 			}
@@ -114,9 +324,58 @@ func CreateChallenge(chal Challenge) {
 	}
 }
 
+// GetChallenges returns the challenges players can see, by name
 func GetChallenges() map[string]Challenge {
-	// Get all challenges from the database, including downloads and ports
-	rows, err := db.Query("SELECT id, name, title, description, category, points, flag, author, build_dir FROM challenges")
+	return getChallenges(false)
+}
+
+// GetAllChallenges also returns challenges that are hidden or disabled, for the admin console
+func GetAllChallenges() map[string]Challenge {
+	return getChallenges(true)
+}
+
+// SetChallengeDisabled hides a challenge from players, or shows it again
+func SetChallengeDisabled(challengeID int, disabled bool) error {
+	_, err := db.Exec("UPDATE challenges SET disabled = ? WHERE id = ?", disabled, challengeID)
+	if err == nil {
+		generation.Add(1)
+	}
+	return err
+}
+
+func getChallenges(includeHidden bool) map[string]Challenge {
+	// Get all challenges from the database, with each kind of detail loaded in one query
+	solves, err := getSolveCounts(scoreboardCutoff())
+	if err != nil {
+		log.Printf("Failed to query solve counts: %v\n", err)
+		return nil
+	}
+	flags, err := getChallengeFlags()
+	if err != nil {
+		log.Printf("Failed to query challenge flags: %v\n", err)
+		return nil
+	}
+	hints, err := getChallengeHints()
+	if err != nil {
+		log.Printf("Failed to query challenge hints: %v\n", err)
+		return nil
+	}
+	reqs, err := getChallengeRequirements()
+	if err != nil {
+		log.Printf("Failed to query challenge requirements: %v\n", err)
+		return nil
+	}
+	downloads, err := getChallengeDownloads()
+	if err != nil {
+		log.Printf("Failed to query challenge downloads: %v\n", err)
+		return nil
+	}
+	ports, err := getChallengePorts()
+	if err != nil {
+		log.Printf("Failed to query challenge ports: %v\n", err)
+		return nil
+	}
+	rows, err := db.Query("SELECT id, name, title, description, category, points, flag, author, build_dir, command, decay_function, minimum_points, decay_solves, release_at, hidden, disabled FROM challenges WHERE (NOT hidden AND NOT disabled) OR ?", includeHidden)
 	if err != nil {
 		log.Printf("Failed to query challenges: %v\n", err)
 		return nil
@@ -125,12 +384,19 @@ func GetChallenges() map[string]Challenge {
 	challenges := make(map[string]Challenge)
 	for rows.Next() {
 		var chal Challenge
-		if err := rows.Scan(&chal.ID, &chal.Name, &chal.Title, &chal.Description, &chal.Category, &chal.Points, &chal.Flag, &chal.Author, &chal.BuildDir); err != nil {
+		var releaseAt sql.NullTime
+		if err := rows.Scan(&chal.ID, &chal.Name, &chal.Title, &chal.Description, &chal.Category, &chal.Points, &chal.Flag, &chal.Author, &chal.BuildDir, &chal.Command, &chal.DecayFunction, &chal.MinimumPoints, &chal.DecaySolves, &releaseAt, &chal.Hidden, &chal.Disabled); err != nil {
 			log.Printf("Failed to scan challenge: %v\n", err)
 			continue
 		}
-		chal.Downloads = GetChallengeDownloads(chal.ID)
-		chal.Ports = GetChallengePorts(chal.ID)
+		chal.ReleaseAt = releaseAt.Time
+		chal.setFlags(flags[chal.ID])
+		chal.Hints = hints[chal.ID]
+		chal.Requires = reqs[chal.ID]
+		chal.Solves = solves[chal.ID]
+		chal.Value = chal.ValueAt(chal.Solves)
+		chal.Downloads = downloads[chal.ID]
+		chal.Ports = ports[chal.ID]
 		challenges[chal.Name] = chal
 	}
 	if err := rows.Err(); err != nil {
@@ -144,54 +410,47 @@ func GetChallenges() map[string]Challenge {
 	return challenges
 }
 
-func GetChallengeDownloads(chalId int) []string {
-	rows, err := db.Query("SELECT path FROM challenge_downloads WHERE challenge_id = ?", chalId)
+// getChallengeDownloads returns the download paths of every challenge by challenge ID
+func getChallengeDownloads() (map[int][]string, error) {
+	rows, err := db.Query("SELECT challenge_id, path FROM challenge_downloads ORDER BY id")
 	if err != nil {
-		log.Printf("Failed to query challenge downloads: %v\n", err)
-		return nil
+		return nil, err
 	}
 	defer rows.Close()
-	var downloads []string
+
+	downloads := make(map[int][]string)
 	for rows.Next() {
+		var challengeID int
 		var path string
-		if err := rows.Scan(&path); err != nil {
-			log.Printf("Failed to scan download path: %v\n", err)
-			continue
+		if err := rows.Scan(&challengeID, &path); err != nil {
+			return nil, err
 		}
-		downloads = append(downloads, path)
-	}
-	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating over downloads: %v\n", err)
-		return nil
+		downloads[challengeID] = append(downloads[challengeID], path)
 	}
-	return downloads
+	return downloads, rows.Err()
 }
 
-func GetChallengePorts(chalId int) []int {
-	rows, err := db.Query("SELECT port FROM challenge_ports WHERE challenge_id = ?", chalId)
+// getChallengePorts returns the instance ports of every challenge by challenge ID
+func getChallengePorts() (map[int][]int, error) {
+	rows, err := db.Query("SELECT challenge_id, port FROM challenge_ports ORDER BY id")
 	if err != nil {
-		log.Printf("Failed to query challenge ports: %v\n", err)
-		return nil
+		return nil, err
 	}
 	defer rows.Close()
-	var ports []int
+
+	ports := make(map[int][]int)
 	for rows.Next() {
-		var port int
-		if err := rows.Scan(&port); err != nil {
-			log.Printf("Failed to scan port: %v\n", err)
-			continue
+		var challengeID, port int
+		if err := rows.Scan(&challengeID, &port); err != nil {
+			return nil, err
 		}
-		ports = append(ports, port)
-	}
-	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating over ports: %v\n", err)
-		return nil
+		ports[challengeID] = append(ports[challengeID], port)
 	}
-	return ports
+	return ports, rows.Err()
 }
 
 func GetChallengeCategories() []string {
-	rows, err := db.Query("SELECT DISTINCT category FROM challenges ORDER BY category")
+	rows, err := db.Query("SELECT DISTINCT category FROM challenges WHERE NOT hidden AND NOT disabled ORDER BY category")
 	if err != nil {
 		log.Printf("Failed to query challenge categories: %v\n", err)
 		return nil
@@ -213,3 +472,43 @@ func GetChallengeCategories() []string {
 	}
 	return categories
 }
+
+// Solver is a team, or a solo player, that solved a challenge
+type Solver struct {
+	ID        int // scoreboard ID, negative for solo players
+	Name      string
+	Username  string // the player who submitted the flag
+	Timestamp time.Time
+}
+
+// GetChallengeSolvers returns who solved a challenge in solve order, counting only the first solve of each team
+func GetChallengeSolvers(challengeID int) ([]Solver, error) {
+	cutoff := sqlTime(scoreboardCutoff())
+	rows, err := db.Query(`
+		SELECT CASE WHEN u.team_id IS NULL THEN -u.id ELSE u.team_id END, COALESCE(t.name, u.username), u.username, s.timestamp
+		FROM submissions s
+		JOIN users u ON s.user_id = u.id
+		LEFT JOIN teams t ON u.team_id = t.id
+		WHERE s.challenge_id = ? AND s.correct AND s.timestamp < ?
+		ORDER BY s.timestamp, s.id
+	`, challengeID, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var solvers []Solver
+	seen := make(map[int]bool)
+	for rows.Next() {
+		var solver Solver
+		if err := rows.Scan(&solver.ID, &solver.Name, &solver.Username, &solver.Timestamp); err != nil {
+			return nil, err
+		}
+		if seen[solver.ID] {
+			continue
+		}
+		seen[solver.ID] = true
+		solvers = append(solvers, solver)
+	}
+	return solvers, rows.Err()
+}
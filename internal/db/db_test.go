@@ -0,0 +1,752 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"ctfsh/internal/config"
+)
+
+// The suite runs against a fresh SQLite file, and against PostgreSQL: the server
+// CTFSH_TEST_POSTGRES points to, or else a throwaway one started from the
+// PostgreSQL binaries installed locally.
+func TestStorage(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) {
+		testStorage(t, filepath.Join(t.TempDir(), "ctfsh.sqlite"))
+	})
+	t.Run("postgres", func(t *testing.T) {
+		dsn := os.Getenv("CTFSH_TEST_POSTGRES")
+		if dsn == "" {
+			dsn = localPostgres(t)
+		}
+		testStorage(t, postgresSchema(t, dsn))
+	})
+}
+
+// localPostgres starts a PostgreSQL server in a temporary directory, listening only on
+// a unix socket there, and returns its URL. Without PostgreSQL installed the test is
+// skipped, except on CI, which has to run it.
+func localPostgres(t *testing.T) string {
+	unavailable := t.Skip
+	if os.Getenv("CI") != "" {
+		unavailable = t.Fatal
+	}
+	bin := postgresBinDir()
+	if bin == "" {
+		unavailable("PostgreSQL is not installed; install it or set CTFSH_TEST_POSTGRES to a postgres:// URL")
+	}
+	if os.Geteuid() == 0 {
+		unavailable("PostgreSQL refuses to run as root; set CTFSH_TEST_POSTGRES to a postgres:// URL instead")
+	}
+
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data")
+	if out, err := exec.Command(filepath.Join(bin, "initdb"), "-D", data, "-U", "postgres", "-A", "trust").CombinedOutput(); err != nil {
+		t.Fatalf("initdb: %v\n%s", err, out)
+	}
+	ctl := filepath.Join(bin, "pg_ctl")
+	options := fmt.Sprintf("-k %s -c listen_addresses=''", dir)
+	if out, err := exec.Command(ctl, "-D", data, "-l", filepath.Join(dir, "postgres.log"), "-o", options, "-w", "start").CombinedOutput(); err != nil {
+		t.Fatalf("pg_ctl start: %v\n%s", err, out)
+	}
+	t.Cleanup(func() {
+		if out, err := exec.Command(ctl, "-D", data, "-m", "immediate", "stop").CombinedOutput(); err != nil {
+			t.Errorf("pg_ctl stop: %v\n%s", err, out)
+		}
+	})
+	return fmt.Sprintf("postgres://postgres@/postgres?host=%s&sslmode=disable", dir)
+}
+
+// postgresBinDir finds pg_ctl on PATH or where Debian and Ubuntu install it, "" if it isn't
+func postgresBinDir() string {
+	if path, err := exec.LookPath("pg_ctl"); err == nil {
+		return filepath.Dir(path)
+	}
+	installed, _ := filepath.Glob("/usr/lib/postgresql/*/bin/pg_ctl")
+	if len(installed) == 0 {
+		return ""
+	}
+	sort.Strings(installed)
+	return filepath.Dir(installed[len(installed)-1])
+}
+
+// baselineSchema is the schema of the first release, which created its tables itself
+const baselineSchema = `
+CREATE TABLE users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT UNIQUE NOT NULL,
+	ssh_key TEXT NOT NULL UNIQUE,
+	team_id INTEGER,
+	FOREIGN KEY(team_id) REFERENCES teams(id)
+);
+CREATE TABLE teams (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT UNIQUE NOT NULL,
+	score INTEGER DEFAULT 0,
+	join_code TEXT UNIQUE NOT NULL
+);
+CREATE TABLE challenges (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT UNIQUE NOT NULL,
+	title TEXT NOT NULL,
+	description TEXT NOT NULL,
+	category TEXT NOT NULL,
+	points INTEGER DEFAULT 0,
+	flag TEXT NOT NULL,
+	author TEXT NOT NULL,
+	build_dir TEXT
+);
+CREATE TABLE challenge_downloads (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	path TEXT NOT NULL,
+	challenge_id INTEGER NOT NULL,
+	FOREIGN KEY(challenge_id) REFERENCES challenges(id)
+);
+CREATE TABLE challenge_ports (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	port INTEGER NOT NULL,
+	challenge_id INTEGER NOT NULL,
+	FOREIGN KEY(challenge_id) REFERENCES challenges(id)
+);
+CREATE TABLE submissions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	challenge_id INTEGER NOT NULL,
+	flag TEXT NOT NULL,
+	correct BOOLEAN NOT NULL,
+	timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY(user_id) REFERENCES users(id),
+	FOREIGN KEY(challenge_id) REFERENCES challenges(id)
+);
+INSERT INTO teams (name, join_code) VALUES ('pwners', 'abc');
+INSERT INTO users (username, ssh_key, team_id) VALUES ('alice', 'key-alice', 1), ('bob', 'key-bob', 1), ('carol', 'key-carol', NULL);
+INSERT INTO challenges (name, title, description, category, points, flag, author, build_dir) VALUES ('warmup', 'warmup', 'Old.', 'Misc', 100, 'cube{warmup}', '', 'chals/warmup');
+INSERT INTO submissions (user_id, challenge_id, flag, correct) VALUES (1, 1, 'cube{warmup}', 1), (2, 1, 'cube{warmup}', 1), (3, 1, 'cube{nope}', 0);
+`
+
+// A database made by the first release is migrated and then works like a new one
+func TestMigrateBaseline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ctfsh.sqlite")
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec(baselineSchema); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	c := config.Default()
+	c.DBPath = path
+	c.ChallengeDir = writeTestChallenges(t)
+	if err := Init(c); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(Close)
+
+	alice, err := GetUserBySSHKey("key-alice")
+	if err != nil || alice.TeamID == nil {
+		t.Fatalf("alice after migrating: %+v, %v", alice, err)
+	}
+	players, err := GetPlayers()
+	if err != nil || len(players) != 3 || players[0].Banned {
+		t.Errorf("players %+v, %v", players, err)
+	}
+	challenges := GetChallenges()
+	if warmup := challenges["warmup"]; warmup.ID != 1 || warmup.Description != "Submit the flag." || warmup.DecayFunction != DecayStatic {
+		t.Errorf("warmup after migrating: %+v", warmup)
+	}
+	// bob's solve duplicates alice's and is dropped
+	board, err := GetScoreboard()
+	if err != nil || len(board) != 2 || board[0].Name != "pwners" || board[0].Score != 100 {
+		t.Errorf("scoreboard %+v, %v", board, err)
+	}
+	carol, err := GetUserByUsername("carol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if correct, err := SubmitFlag(carol.ID, challenges["warmup"].ID, "cube{warmup}"); !correct || err != nil {
+		t.Errorf("carol solving after migrating: %v, %v", correct, err)
+	}
+}
+
+// postgresSchema creates an empty schema for one run and returns a DSN that uses it
+func postgresSchema(t *testing.T, dsn string) string {
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("ctfsh_test_%d", time.Now().UnixNano())
+	if _, err := conn.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := conn.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Error(err)
+		}
+		conn.Close()
+	})
+	if strings.Contains(dsn, "?") {
+		return dsn + "&search_path=" + schema
+	}
+	return dsn + "?search_path=" + schema
+}
+
+// testChallenges are the ctfsh.yml files the suite loads, by directory
+var testChallenges = map[string]string{
+	"warmup": `challenge:
+  name: warmup
+  category: Misc
+  description: Submit the flag.
+  flag: cube{warmup}
+  points: 100
+  hints:
+    - text: It is cube{warmup}
+      cost: 10
+`,
+	"decay": `challenge:
+  name: decay
+  category: Crypto
+  description: Worth less the more teams solve it.
+  flag: cube{decay}
+  points: 500
+  scoring:
+    function: linear
+    minimum: 100
+    decay: 5
+  requires:
+    - warmup
+  hints:
+    - text: Solve warmup first.
+      cost: 20
+`,
+}
+
+func testStorage(t *testing.T, dsn string) {
+	c := config.Default()
+	c.DBPath = dsn
+	c.ChallengeDir = writeTestChallenges(t)
+	c.BloodBonuses = []int{50}
+	c.SubmitLimit = 2
+	c.SubmitWindow = time.Minute
+	c.Lockout = time.Minute
+	if err := Init(c); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(Close)
+
+	t.Run("migrations", func(t *testing.T) {
+		migrations, err := loadMigrations()
+		if err != nil {
+			t.Fatal(err)
+		}
+		version, err := SchemaVersion()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != len(migrations) {
+			t.Errorf("schema version %d, want %d", version, len(migrations))
+		}
+		applied, err := Migrate()
+		if err != nil || len(applied) > 0 {
+			t.Errorf("migrating again applied %v, %v", applied, err)
+		}
+	})
+
+	challenges := GetChallenges()
+	warmup, decay := challenges["warmup"], challenges["decay"]
+	if len(challenges) != 2 || warmup.ID == 0 || decay.ID == 0 {
+		t.Fatalf("loaded challenges %v", challenges)
+	}
+	if len(warmup.Hints) != 1 || decay.DecayFunction != DecayLinear || len(decay.Requires) != 1 {
+		t.Fatalf("challenge details not stored: %+v %+v", warmup, decay)
+	}
+
+	alice := createTestUser(t, "alice")
+	bob := createTestUser(t, "bob")
+	carol := createTestUser(t, "carol")
+	team, err := CreateAndJoinTeam(alice.ID, "pwners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := JoinTeam(bob.ID, "pwners"); err != nil {
+		t.Fatal(err)
+	}
+	alice, bob = reloadUser(t, alice), reloadUser(t, bob)
+
+	t.Run("submissions", func(t *testing.T) {
+		// Load the standings first, so the solves below are added to them as they come in
+		if _, err := GetLiveScoreboard(); err != nil {
+			t.Fatal(err)
+		}
+		if correct, err := SubmitFlag(alice.ID, warmup.ID, "cube{nope}"); correct || err != nil {
+			t.Errorf("wrong flag: %v, %v", correct, err)
+		}
+		if correct, err := SubmitFlag(alice.ID, warmup.ID, "cube{warmup}"); !correct || err != nil {
+			t.Errorf("correct flag: %v, %v", correct, err)
+		}
+		if _, err := SubmitFlag(bob.ID, warmup.ID, "cube{warmup}"); err == nil || !strings.Contains(err.Error(), "already solved by alice") {
+			t.Errorf("teammate solving again: %v", err)
+		}
+		// As when bob submits at the same time as alice, after both were checked for earlier solves
+		if _, err := db.Insert("INSERT INTO submissions (user_id, challenge_id, flag, correct, scoreboard_id) VALUES (?, ?, ?, ?, ?) ON CONFLICT DO NOTHING",
+			bob.ID, warmup.ID, "cube{warmup}", true, team.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("teammate's simultaneous solve: %v", err)
+		}
+		if _, err := SubmitFlag(carol.ID, decay.ID, "cube{decay}"); err == nil || !strings.Contains(err.Error(), "locked") {
+			t.Errorf("solving before the prerequisite: %v", err)
+		}
+		if correct, err := SubmitFlag(carol.ID, warmup.ID, "cube{warmup}"); !correct || err != nil {
+			t.Errorf("second team solving: %v, %v", correct, err)
+		}
+		if correct, err := SubmitFlag(carol.ID, decay.ID, "cube{decay}"); !correct || err != nil {
+			t.Errorf("solving after the prerequisite: %v, %v", correct, err)
+		}
+
+		solved, err := GetSolvedChallenges(bob)
+		if err != nil || !solved[warmup.ID] {
+			t.Errorf("bob's team solves: %v, %v", solved, err)
+		}
+		solvers, err := GetChallengeSolvers(warmup.ID)
+		if err != nil || len(solvers) != 2 || solvers[0].ID != team.ID || solvers[1].ID != -carol.ID {
+			t.Errorf("warmup solvers: %+v, %v", solvers, err)
+		}
+
+		cached, err := GetLiveScoreboard()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fresh, err := loadStandings(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, entry := range fresh.teams() {
+			if i >= len(cached) || cached[i].ID != entry.ID || cached[i].Score != entry.Score || !cached[i].LastChange.Equal(entry.LastChange) {
+				t.Errorf("cached standings %+v, from the database %+v", cached, fresh.teams())
+				break
+			}
+		}
+	})
+
+	t.Run("lockout", func(t *testing.T) {
+		for range 2 {
+			if _, err := SubmitFlag(alice.ID, decay.ID, "cube{guess}"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var lockout *LockoutError
+		if _, err := SubmitFlag(bob.ID, decay.ID, "cube{guess}"); !errors.As(err, &lockout) {
+			t.Errorf("third wrong flag in a row: %v", err)
+		}
+		until, err := GetLockout(bob, decay.ID)
+		if err != nil || !until.After(time.Now()) {
+			t.Errorf("lockout ends at %v, %v", until, err)
+		}
+	})
+
+	t.Run("scoreboard", func(t *testing.T) {
+		hints, err := GetHints(warmup.ID, bob)
+		if err != nil || len(hints) != 1 {
+			t.Fatalf("hints: %v, %v", hints, err)
+		}
+		if err := UnlockHint(bob, hints[0].ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := UnlockHint(alice, hints[0].ID); err == nil {
+			t.Error("teammate unlocked the hint again")
+		}
+		if _, err := db.Exec("INSERT INTO hint_unlocks (hint_id, user_id, team_id) VALUES (?, ?, ?)", hints[0].ID, alice.ID, team.ID); err == nil {
+			t.Error("a second unlock of the hint for the team was stored")
+		}
+		if err := AdjustScore(team.ID, 25, "found a bug"); err != nil {
+			t.Fatal(err)
+		}
+
+		// pwners: warmup with first blood, the hint and the adjustment; carol: both challenges, first blood on decay
+		want := map[int]int{team.ID: 100 + 50 - 10 + 25, -carol.ID: 100 + 500 + 50}
+		board, err := GetScoreboard()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(board) != 2 || board[0].ID != team.ID || board[1].ID != -carol.ID {
+			t.Fatalf("scoreboard %+v", board)
+		}
+		timelines, err := GetLiveScoreTimelines()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range board {
+			if entry.Score != want[entry.ID] {
+				t.Errorf("%s scored %d, want %d", entry.Name, entry.Score, want[entry.ID])
+			}
+			points := timelines[entry.ID]
+			if len(points) == 0 || points[len(points)-1].Score != want[entry.ID] {
+				t.Errorf("%s timeline %v ends away from %d", entry.Name, points, want[entry.ID])
+			}
+		}
+
+		points, err := GetTeamMemberPoints(team.ID)
+		if err != nil || points[alice.ID] != 100+50 || points[bob.ID] != -10 {
+			t.Errorf("member points: %v, %v", points, err)
+		}
+
+		solves, err := GetSolves(team.ID)
+		if err != nil || len(solves) != 1 || solves[0].Username != "alice" || solves[0].Points != 100 {
+			t.Errorf("team solves: %+v, %v", solves, err)
+		}
+
+		// Another server sharing the database adjusts carol's score
+		if _, err := db.Exec("INSERT INTO awards (user_id, kind, points, note) VALUES (?, ?, ?, ?)", carol.ID, AwardAdjustment, 5, "elsewhere"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("UPDATE score_generation SET generation = generation + 1"); err != nil {
+			t.Fatal(err)
+		}
+		board, err = GetScoreboard()
+		if err != nil || len(board) != 2 || board[1].Score != want[-carol.ID]+5 {
+			t.Errorf("scoreboard after a change on another server: %+v, %v", board, err)
+		}
+	})
+
+	t.Run("ties", func(t *testing.T) {
+		zed, amy := createTestUser(t, "zed"), createTestUser(t, "amy")
+		for _, user := range []*User{zed, amy} {
+			if correct, err := SubmitFlag(user.ID, warmup.ID, "cube{warmup}"); !correct || err != nil {
+				t.Fatalf("%s solving: %v, %v", user.Username, correct, err)
+			}
+		}
+		// Both score 100; zed got there an hour earlier and ranks first despite the name.
+		// yan got 100 from an award another hour before that, without solving anything.
+		yan := createTestUser(t, "yan")
+		if err := AdjustScore(-yan.ID, 100, "bonus"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("UPDATE submissions SET timestamp = ? WHERE user_id = ?", time.Now().Add(-time.Hour).UTC().Format(time.DateTime), zed.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("UPDATE awards SET timestamp = ? WHERE user_id = ?", time.Now().Add(-2*time.Hour).UTC().Format(time.DateTime), yan.ID); err != nil {
+			t.Fatal(err)
+		}
+		invalidateScores()
+		board, err := GetScoreboard()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ranked []string
+		for _, entry := range board {
+			if entry.Score == 100 {
+				ranked = append(ranked, entry.Name)
+			}
+		}
+		if strings.Join(ranked, ",") != "yan,zed,amy" {
+			t.Errorf("entries on 100 ranked %v", ranked)
+		}
+	})
+
+	t.Run("bloods", func(t *testing.T) {
+		// Two solves both recorded before either blood is, as when they come in at the same time
+		dan, eve := createTestUser(t, "dan"), createTestUser(t, "eve")
+		var ids []int64
+		for _, user := range []*User{dan, eve} {
+			id, err := db.Insert("INSERT INTO submissions (user_id, challenge_id, flag, correct) VALUES (?, ?, ?, ?)", user.ID, decay.ID, "cube{decay}", true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, id)
+		}
+		recordBlood(ids[1], eve.ID, nil, decay.ID)
+		recordBlood(ids[0], dan.ID, nil, decay.ID)
+		invalidateScores()
+
+		bloods, err := GetChallengeBloods()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var places []string
+		for _, blood := range bloods[decay.ID] {
+			places = append(places, fmt.Sprintf("%d:%s", blood.Place, blood.Username))
+		}
+		if strings.Join(places, ",") != "1:carol,2:dan,3:eve" {
+			t.Errorf("decay bloods %v", places)
+		}
+	})
+
+	t.Run("shared flags", func(t *testing.T) {
+		chal := Challenge{Name: "shared"}
+		chal.setFlags([]ChallengeFlag{
+			{Type: FlagCaseInsensitive, Flag: "cube{" + FlagTemplate + "}"},
+			{Type: FlagRegex, Flag: `cube\{` + FlagTemplate + `-[0-9]+\}`},
+		})
+		pwners := flagOwner(alice.ID, alice.TeamID)
+		flag := expandFlag(chal, chal.Flags[0].Flag, pwners)
+		for _, flag := range []string{flag, strings.ToUpper(flag), strings.Replace(flag, "}", "-42}", 1)} {
+			if !matchesFlag(chal, pwners, flag) || matchesFlag(chal, flagOwner(carol.ID, nil), flag) {
+				t.Errorf("%s is not only pwners' flag", flag)
+			}
+			if owner, shared, err := findFlagOwner(chal, flag, carol.ID, nil); !shared || owner != "pwners" || err != nil {
+				t.Errorf("carol submitting %s: %q, %v, %v", flag, owner, shared, err)
+			}
+			if _, shared, err := findFlagOwner(chal, flag, bob.ID, bob.TeamID); shared || err != nil {
+				t.Errorf("bob submitting his own team's %s: %v, %v", flag, shared, err)
+			}
+		}
+		// Players who signed up after the cache was filled are found too
+		frank := createTestUser(t, "frank")
+		flag = expandFlag(chal, chal.Flags[0].Flag, flagOwner(frank.ID, nil))
+		if owner, shared, err := findFlagOwner(chal, flag, carol.ID, nil); !shared || owner != "frank" || err != nil {
+			t.Errorf("carol submitting frank's flag: %q, %v, %v", owner, shared, err)
+		}
+		if _, shared, err := findFlagOwner(chal, "cube{0123456789abcdef}", carol.ID, nil); shared || err != nil {
+			t.Errorf("made up flag: %v, %v", shared, err)
+		}
+		// Frank got his flag while playing solo, then started a team
+		if _, err := CreateAndJoinTeam(frank.ID, "franks"); err != nil {
+			t.Fatal(err)
+		}
+		frank = reloadUser(t, frank)
+		if _, shared, err := findFlagOwner(chal, flag, frank.ID, frank.TeamID); shared || err != nil {
+			t.Errorf("frank submitting his solo flag from his team: %v, %v", shared, err)
+		}
+	})
+
+	t.Run("freeze", func(t *testing.T) {
+		score := func(board []Team, id int) int {
+			for _, entry := range board {
+				if entry.ID == id {
+					return entry.Score
+				}
+			}
+			return 0
+		}
+		before, err := GetScoreboard()
+		if err != nil {
+			t.Fatal(err)
+		}
+		bloods, err := GetChallengeBloods()
+		if err != nil {
+			t.Fatal(err)
+		}
+		points, err := GetTeamMemberPoints(team.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Freeze at the start of the next second, as timestamps are stored to the second
+		freeze := time.Now().Truncate(time.Second).Add(time.Second)
+		time.Sleep(time.Until(freeze))
+		cfg.FreezeAt = freeze
+		defer func() { cfg.FreezeAt = time.Time{} }()
+
+		// After the freeze gus lowers decay's value and takes a fourth warmup blood, and bob gets an award
+		gus := createTestUser(t, "gus")
+		for _, chal := range []Challenge{warmup, decay} {
+			if correct, err := SubmitFlag(gus.ID, chal.ID, "cube{"+chal.Name+"}"); !correct || err != nil {
+				t.Fatalf("gus solving %s: %v, %v", chal.Name, correct, err)
+			}
+		}
+		if _, err := db.Exec("INSERT INTO awards (user_id, challenge_id, kind, place, points) VALUES (?, ?, ?, ?, ?)", gus.ID, warmup.ID, AwardBlood, 4, 0); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("INSERT INTO awards (user_id, kind, points, note) VALUES (?, ?, ?, ?)", bob.ID, AwardAdjustment, 5, "late"); err != nil {
+			t.Fatal(err)
+		}
+		invalidateScores()
+
+		frozen, err := GetScoreboard()
+		if err != nil {
+			t.Fatal(err)
+		}
+		live, err := GetLiveScoreboard()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, id := range []int{team.ID, -carol.ID} {
+			if score(frozen, id) != score(before, id) || score(live, id) == score(before, id) {
+				t.Errorf("entry %d scored %d before the freeze, %d frozen and %d live", id, score(before, id), score(frozen, id), score(live, id))
+			}
+		}
+		if got, err := GetChallengeBloods(); err != nil || len(got[warmup.ID]) != len(bloods[warmup.ID]) {
+			t.Errorf("frozen warmup bloods %v, want %v, %v", got[warmup.ID], bloods[warmup.ID], err)
+		}
+		if got, err := GetTeamMemberPoints(team.ID); err != nil || got[bob.ID] != points[bob.ID] {
+			t.Errorf("frozen member points %v, want %v, %v", got, points, err)
+		}
+
+		cfg.FreezeAt = time.Time{}
+		if got, err := GetChallengeBloods(); err != nil || len(got[warmup.ID]) != len(bloods[warmup.ID])+1 {
+			t.Errorf("warmup bloods after the freeze %v, %v", got[warmup.ID], err)
+		}
+		if got, err := GetTeamMemberPoints(team.ID); err != nil || got[bob.ID] != points[bob.ID]+5 {
+			t.Errorf("member points after the freeze %v, %v", got, err)
+		}
+	})
+
+	t.Run("hints", func(t *testing.T) {
+		warmupHint, decayHint := warmup.Hints[0].ID, decay.Hints[0].ID
+		hal := createTestUser(t, "hal")
+		if err := UnlockHint(hal, decayHint); err == nil || !strings.Contains(err.Error(), "locked") {
+			t.Errorf("unlocking a hint of a locked challenge: %v", err)
+		}
+		if err := SetChallengeDisabled(warmup.ID, true); err != nil {
+			t.Fatal(err)
+		}
+		if err := UnlockHint(hal, warmupHint); err == nil {
+			t.Error("unlocked a hint of a disabled challenge")
+		}
+		if err := SetChallengeDisabled(warmup.ID, false); err != nil {
+			t.Fatal(err)
+		}
+
+		// ivy and jon both pay for the warmup hint while solo, then team up: the team sees it once and pays for it once
+		ivy, jon := createTestUser(t, "ivy"), createTestUser(t, "jon")
+		for _, user := range []*User{ivy, jon} {
+			if err := UnlockHint(user, warmupHint); err != nil {
+				t.Fatal(err)
+			}
+		}
+		ivys, err := CreateAndJoinTeam(ivy.ID, "ivys")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := JoinTeam(jon.ID, "ivys"); err != nil {
+			t.Fatal(err)
+		}
+		ivy, jon = reloadUser(t, ivy), reloadUser(t, jon)
+		if hints, err := GetHints(warmup.ID, jon); err != nil || len(hints) != 1 || !hints[0].Unlocked {
+			t.Errorf("team's warmup hints: %+v, %v", hints, err)
+		}
+		if err := UnlockHint(jon, warmupHint); err == nil {
+			t.Error("unlocked the team's hint again")
+		}
+		board, err := GetScoreboard()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i := slices.IndexFunc(board, func(entry Team) bool { return entry.ID == ivys.ID }); i < 0 || board[i].Score != -10 {
+			t.Errorf("ivys on the scoreboard %+v", board)
+		}
+		if points, err := GetTeamMemberPoints(ivys.ID); err != nil || points[ivy.ID] != -10 || points[jon.ID] != 0 {
+			t.Errorf("ivys member points: %v, %v", points, err)
+		}
+	})
+
+	t.Run("settings", func(t *testing.T) {
+		for _, value := range []string{"one", "two"} {
+			if err := setSetting("test", value); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if value, err := getSetting("test"); value != "two" || err != nil {
+			t.Errorf("setting is %q, %v", value, err)
+		}
+	})
+
+	t.Run("announcements", func(t *testing.T) {
+		a, err := CreateAnnouncement(alice.ID, "Hello")
+		if err != nil || a.Author != "alice" || a.Message != "Hello" || a.Timestamp.IsZero() {
+			t.Fatalf("announcement %+v, %v", a, err)
+		}
+		all, err := GetAnnouncements()
+		if err != nil || len(all) != 1 || all[0].ID != a.ID {
+			t.Errorf("announcements %+v, %v", all, err)
+		}
+	})
+
+	t.Run("reload", func(t *testing.T) {
+		changed := strings.Replace(testChallenges["decay"], "Worth less", "Worth ever less", 1)
+		if err := os.WriteFile(filepath.Join(c.ChallengeDir, "decay", "ctfsh.yml"), []byte(changed), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if names := LoadChallenges(); len(names) != 1 || names[0] != "decay" {
+			t.Errorf("reload changed %v", names)
+		}
+		decay := GetChallenges()["decay"]
+		if !strings.HasPrefix(decay.Description, "Worth ever less") || len(decay.Requires) != 1 || len(decay.Flags) != 1 {
+			t.Errorf("decay after reloading: %+v", decay)
+		}
+	})
+
+	t.Run("newer schema", func(t *testing.T) {
+		if _, err := db.Exec("INSERT INTO schema_version (version) VALUES (?)", 999); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Migrate(); err == nil || !strings.Contains(err.Error(), "newer") {
+			t.Errorf("migrating a newer schema: %v", err)
+		}
+	})
+}
+
+// writeTestChallenges writes testChallenges to a new challenge directory
+func writeTestChallenges(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range testChallenges {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name, "ctfsh.yml"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func createTestUser(t *testing.T, name string) *User {
+	t.Helper()
+	user, err := CreateUser(name, "key-"+name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// reloadUser picks up a user's team after joining one, looking them up by key as on login
+func reloadUser(t *testing.T, user *User) *User {
+	t.Helper()
+	user, err := GetUserBySSHKey(user.SSHKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestPostgresRebind(t *testing.T) {
+	for query, want := range map[string]string{
+		"SELECT ? WHERE a = ?":                      "SELECT $1 WHERE a = $2",
+		"SELECT '?' WHERE a = ?":                    "SELECT '?' WHERE a = $1",
+		"-- SQLite's default?\nSELECT 'it''s' || ?": "-- SQLite's default?\nSELECT 'it''s' || $1",
+	} {
+		if got := (postgres{}).rebind(query); got != want {
+			t.Errorf("rebind(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
+
+func TestValueAt(t *testing.T) {
+	// 500 points decaying to 100 over 10 solves after the first
+	for _, tt := range []struct {
+		function string
+		want     [5]int // at 0, 1, 10, 11 and 20 solves
+	}{
+		{DecayStatic, [5]int{500, 500, 500, 500, 500}},
+		{DecayLinear, [5]int{500, 500, 140, 100, 100}},
+		{DecayLogarithmic, [5]int{500, 500, 116, 100, 100}},
+		{DecayParabolic, [5]int{500, 500, 176, 100, 100}},
+	} {
+		chal := Challenge{Points: 500, MinimumPoints: 100, DecayFunction: tt.function, DecaySolves: 10}
+		for i, solves := range []int{0, 1, 10, 11, 20} {
+			if got := chal.ValueAt(solves); got != tt.want[i] {
+				t.Errorf("%s at %d solves = %d, want %d", tt.function, solves, got, tt.want[i])
+			}
+		}
+	}
+}
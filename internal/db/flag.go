@@ -0,0 +1,423 @@
+package db
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FlagTemplate is replaced with a per-team HMAC in flags that contain it
+const FlagTemplate = "{{hmac}}"
+
+// Flag types, deciding how a submission is compared
+const (
+	FlagStatic          = "static"
+	FlagRegex           = "regex"
+	FlagCaseInsensitive = "case_insensitive"
+)
+
+// ChallengeFlag is one accepted flag of a challenge
+type ChallengeFlag struct {
+	Type string `yaml:"type"`
+	Flag string `yaml:"flag"`
+
+	pattern *regexp.Regexp // regex and per-team flags, with the per-team parts captured as hmac
+}
+
+// UnmarshalYAML also accepts a plain string as a static flag
+func (f *ChallengeFlag) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		f.Type = FlagStatic
+		return node.Decode(&f.Flag)
+	}
+	type plain ChallengeFlag
+	return node.Decode((*plain)(f))
+}
+
+// compile prepares the pattern of a regex or per-team flag, so that submissions are
+// checked against it without compiling anything
+func (f *ChallengeFlag) compile() error {
+	f.pattern = nil
+	if f.Type != FlagRegex && !strings.Contains(f.Flag, FlagTemplate) {
+		return nil
+	}
+	parts := strings.Split(strings.TrimSpace(f.Flag), FlagTemplate)
+	if f.Type != FlagRegex {
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+	}
+	pattern := "^(?:" + strings.Join(parts, "(?P<hmac>[0-9a-f]{16})") + ")$"
+	if f.Type == FlagCaseInsensitive {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	f.pattern = re
+	return nil
+}
+
+// tags returns the per-team parts of a submission matching the flag's pattern, lowercased
+func (f ChallengeFlag) tags(submitted string) ([]string, bool) {
+	match := f.pattern.FindStringSubmatch(submitted)
+	if match == nil {
+		return nil, false
+	}
+	var tags []string
+	for i, name := range f.pattern.SubexpNames() {
+		if name == "hmac" && match[i] != "" {
+			tags = append(tags, strings.ToLower(match[i]))
+		}
+	}
+	return tags, true
+}
+
+// flagSecret keys the HMAC in per-team flags
+var flagSecret []byte
+
+// CheatEvent is a submission of a flag that was generated for another team or player
+type CheatEvent struct {
+	ID        int
+	UserID    int
+	Username  string
+	Challenge string
+	Owner     string // team or player the flag belongs to
+	Flag      string
+	Timestamp time.Time
+}
+
+// PerTeam reports whether every team gets its own flag for the challenge
+func (c Challenge) PerTeam() bool {
+	if strings.Contains(c.Flag, FlagTemplate) {
+		return true
+	}
+	for _, flag := range c.Flags {
+		if strings.Contains(flag.Flag, FlagTemplate) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadFlags checks the flags from a challenge's config and picks the one handed out
+// to instances and downloads: flag if set, otherwise the first non-regex flag
+func loadFlags(name, flag string, flags []ChallengeFlag) (string, []ChallengeFlag) {
+	var accepted []ChallengeFlag
+	if flag != "" {
+		accepted = append(accepted, ChallengeFlag{Type: FlagStatic, Flag: flag})
+	}
+	for _, f := range flags {
+		f.Type = strings.ToLower(strings.TrimSpace(f.Type))
+		if f.Type == "" {
+			f.Type = FlagStatic
+		}
+		switch f.Type {
+		case FlagStatic, FlagCaseInsensitive:
+		case FlagRegex:
+			if err := f.compile(); err != nil {
+				log.Printf("Challenge %s has an invalid regex flag, skipping it: %v\n", name, err)
+				continue
+			}
+		default:
+			log.Printf("Challenge %s has a flag of unknown type %q, skipping it\n", name, f.Type)
+			continue
+		}
+		if f.Flag == "" {
+			continue
+		}
+		accepted = append(accepted, f)
+		if flag == "" && f.Type != FlagRegex {
+			flag = f.Flag
+		}
+	}
+	return flag, accepted
+}
+
+// getChallengeFlags returns the accepted flags of every challenge by challenge ID
+func getChallengeFlags() (map[int][]ChallengeFlag, error) {
+	rows, err := db.Query("SELECT challenge_id, type, flag FROM challenge_flags ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flags := make(map[int][]ChallengeFlag)
+	for rows.Next() {
+		var challengeID int
+		var flag ChallengeFlag
+		if err := rows.Scan(&challengeID, &flag.Type, &flag.Flag); err != nil {
+			return nil, err
+		}
+		flags[challengeID] = append(flags[challengeID], flag)
+	}
+	return flags, rows.Err()
+}
+
+// getFlags returns the accepted flags of one challenge
+func getFlags(challengeID int) ([]ChallengeFlag, error) {
+	rows, err := db.Query("SELECT type, flag FROM challenge_flags WHERE challenge_id = ? ORDER BY id", challengeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var flags []ChallengeFlag
+	for rows.Next() {
+		var flag ChallengeFlag
+		if err := rows.Scan(&flag.Type, &flag.Flag); err != nil {
+			return nil, err
+		}
+		flags = append(flags, flag)
+	}
+	return flags, rows.Err()
+}
+
+// setFlags attaches the challenge's accepted flags, falling back to
+// its single flag for challenges stored before flag lists existed
+func (c *Challenge) setFlags(flags []ChallengeFlag) {
+	c.Flags = nil
+	if len(flags) == 0 {
+		flags = []ChallengeFlag{{Type: FlagStatic, Flag: c.Flag}}
+	}
+	for _, flag := range flags {
+		if err := flag.compile(); err != nil {
+			log.Printf("Invalid flag for challenge %s: %v\n", c.Name, err)
+			continue
+		}
+		c.Flags = append(c.Flags, flag)
+	}
+}
+
+// matchesFlag reports whether a submission is one of the challenge's flags as generated for owner
+func matchesFlag(chal Challenge, owner, submitted string) bool {
+	tag := flagTag(chal, owner)
+	for _, flag := range chal.Flags {
+		if flag.pattern != nil {
+			tags, ok := flag.tags(submitted)
+			if ok && !slices.ContainsFunc(tags, func(t string) bool { return t != tag }) {
+				return true
+			}
+			continue
+		}
+		expected := strings.TrimSpace(flag.Flag)
+		switch flag.Type {
+		case FlagCaseInsensitive:
+			if strings.EqualFold(submitted, expected) {
+				return true
+			}
+		default:
+			if submitted == expected {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FlagOwner identifies who a user's flags are generated for: their team, or themselves when solo
+func FlagOwner(user *User) string {
+	return flagOwner(user.ID, user.TeamID)
+}
+
+func flagOwner(userID int, teamID *int) string {
+	if teamID != nil {
+		return fmt.Sprintf("team-%d", *teamID)
+	}
+	return fmt.Sprintf("user-%d", userID)
+}
+
+// FlagFor returns the challenge's flag as the user's team should see it
+func FlagFor(chal Challenge, user *User) string {
+	return flagForOwner(chal, FlagOwner(user))
+}
+
+func flagForOwner(chal Challenge, owner string) string {
+	return expandFlag(chal, chal.Flag, owner)
+}
+
+// expandFlag fills in the per-team part of one of the challenge's flags
+func expandFlag(chal Challenge, flag, owner string) string {
+	if !strings.Contains(flag, FlagTemplate) {
+		return flag
+	}
+	mac := hmac.New(sha256.New, flagSecret)
+	mac.Write([]byte(chal.Name + "/" + owner))
+	return strings.ReplaceAll(flag, FlagTemplate, hex.EncodeToString(mac.Sum(nil))[:16])
+}
+
+// loadFlagSecret uses the configured flag secret, or one generated on first start and kept in the database
+func loadFlagSecret() error {
+	resetFlagOwners()
+	if cfg.FlagSecret != "" {
+		flagSecret = []byte(cfg.FlagSecret)
+		return nil
+	}
+	secret, err := getSetting("flag_secret")
+	if errors.Is(err, sql.ErrNoRows) {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		secret = hex.EncodeToString(b)
+		err = setSetting("flag_secret", secret)
+	}
+	if err != nil {
+		return fmt.Errorf("loading flag secret: %w", err)
+	}
+	flagSecret = []byte(secret)
+	return nil
+}
+
+// flagOwners caches, per challenge, which team or player each HMAC tag was generated for. Tags
+// only depend on the flag secret, so the cache only grows as teams and players sign up.
+var flagOwners = struct {
+	sync.Mutex
+	challenges map[string]*ownerTags
+}{challenges: make(map[string]*ownerTags)}
+
+type ownerTags struct {
+	maxTeam, maxUser int               // highest team and user IDs with tags in owners
+	owners           map[string]string // HMAC tag to team-N or user-N
+}
+
+func resetFlagOwners() {
+	flagOwners.Lock()
+	flagOwners.challenges = make(map[string]*ownerTags)
+	flagOwners.Unlock()
+}
+
+// flagTags returns the HMAC tags in a submission shaped like one of the challenge's per-team flags
+func flagTags(chal Challenge, submitted string) []string {
+	var tags []string
+	for _, flag := range chal.Flags {
+		if flag.pattern != nil {
+			found, _ := flag.tags(submitted)
+			tags = append(tags, found...)
+		}
+	}
+	return tags
+}
+
+// flagTag returns the HMAC tag of the challenge's flags for owner
+func flagTag(chal Challenge, owner string) string {
+	return expandFlag(chal, FlagTemplate, owner)
+}
+
+// ownerOfTag looks a tag up in the challenge's cache, adding the teams and players that
+// signed up since it was last filled in when the tag is not in it
+func ownerOfTag(chal Challenge, tag string) (string, error) {
+	flagOwners.Lock()
+	defer flagOwners.Unlock()
+	tags := flagOwners.challenges[chal.Name]
+	if tags == nil {
+		tags = &ownerTags{owners: make(map[string]string)}
+		flagOwners.challenges[chal.Name] = tags
+	}
+	if owner, ok := tags.owners[tag]; ok {
+		return owner, nil
+	}
+
+	var maxTeam, maxUser int
+	err := db.QueryRow("SELECT (SELECT COALESCE(MAX(id), 0) FROM teams), (SELECT COALESCE(MAX(id), 0) FROM users)").Scan(&maxTeam, &maxUser)
+	if err != nil || (maxTeam == tags.maxTeam && maxUser == tags.maxUser) {
+		return "", err
+	}
+	for id := tags.maxTeam + 1; id <= maxTeam; id++ {
+		owner := fmt.Sprintf("team-%d", id)
+		tags.owners[flagTag(chal, owner)] = owner
+	}
+	for id := tags.maxUser + 1; id <= maxUser; id++ {
+		owner := fmt.Sprintf("user-%d", id)
+		tags.owners[flagTag(chal, owner)] = owner
+	}
+	tags.maxTeam, tags.maxUser = maxTeam, maxUser
+	return tags.owners[tag], nil
+}
+
+// findFlagOwner returns the team or player other than the submitter that a per-team flag was generated for.
+// The submitter's own player flag doesn't count, as they may have been handed it before joining their team.
+func findFlagOwner(chal Challenge, flag string, userID int, teamID *int) (string, bool, error) {
+	self, solo := flagOwner(userID, teamID), flagOwner(userID, nil)
+	for _, tag := range flagTags(chal, flag) {
+		owner, err := ownerOfTag(chal, tag)
+		if err != nil {
+			return "", false, err
+		}
+		if owner == "" || owner == self || owner == solo || !matchesFlag(chal, owner, flag) {
+			continue
+		}
+		name, err := ownerName(owner)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue // deleted since
+		}
+		if err != nil {
+			return "", false, err
+		}
+		return name, true, nil
+	}
+	return "", false, nil
+}
+
+// ownerName returns the name of the team or player a flag owner stands for
+func ownerName(owner string) (string, error) {
+	query := "SELECT username FROM users WHERE id = ?"
+	id, team := strings.CutPrefix(owner, "team-")
+	if team {
+		query = "SELECT name FROM teams WHERE id = ?"
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(id, "user-"))
+	if err != nil {
+		return "", err
+	}
+	var name string
+	err = db.QueryRow(query, n).Scan(&name)
+	return name, err
+}
+
+func recordCheatEvent(userID, challengeID int, owner, flag string) {
+	_, err := db.Exec("INSERT INTO cheat_events (user_id, challenge_id, owner, flag) VALUES (?, ?, ?, ?)",
+		userID, challengeID, owner, flag)
+	if err != nil {
+		log.Printf("Failed to insert cheat event: %v\n", err)
+	}
+}
+
+// GetCheatEvents returns all recorded flag sharing, newest first
+func GetCheatEvents() ([]CheatEvent, error) {
+	rows, err := db.Query(`
+		SELECT e.id, e.user_id, u.username, c.name, e.owner, e.flag, e.timestamp
+		FROM cheat_events e
+		JOIN users u ON e.user_id = u.id
+		JOIN challenges c ON e.challenge_id = c.id
+		ORDER BY e.timestamp DESC, e.id DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []CheatEvent
+	for rows.Next() {
+		var event CheatEvent
+		if err := rows.Scan(&event.ID, &event.UserID, &event.Username, &event.Challenge, &event.Owner, &event.Flag, &event.Timestamp); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,206 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+
+	"gopkg.in/yaml.v3"
+
+	"ctfsh/internal/events"
+)
+
+type Hint struct {
+	ID          int
+	ChallengeID int
+	Position    int
+	Content     string `yaml:"text"`
+	Cost        int    `yaml:"cost"`
+	Unlocked    bool
+}
+
+// UnmarshalYAML also accepts a plain string as a free hint
+func (h *Hint) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&h.Content)
+	}
+	type plain Hint
+	return node.Decode((*plain)(h))
+}
+
+func createHints(q execer, challengeID int, hints []Hint) {
+	for i, hint := range hints {
+		_, err := q.Exec("INSERT INTO hints (challenge_id, position, content, cost) VALUES (?, ?, ?, ?)",
+			challengeID, i+1, hint.Content, max(hint.Cost, 0))
+		if err != nil {
+			log.Printf("Failed to insert hint: %v\n", err)
+		}
+	}
+}
+
+// updateHints rewrites a challenge's hints in place, so unlocks of the hints that are left stay valid
+func updateHints(q execer, challengeID int, hints []Hint) {
+	for i, hint := range hints {
+		result, err := q.Exec("UPDATE hints SET content = ?, cost = ? WHERE challenge_id = ? AND position = ?",
+			hint.Content, max(hint.Cost, 0), challengeID, i+1)
+		if err != nil {
+			log.Printf("Failed to update hint: %v\n", err)
+			continue
+		}
+		if n, err := result.RowsAffected(); err == nil && n == 0 {
+			_, err := q.Exec("INSERT INTO hints (challenge_id, position, content, cost) VALUES (?, ?, ?, ?)",
+				challengeID, i+1, hint.Content, max(hint.Cost, 0))
+			if err != nil {
+				log.Printf("Failed to insert hint: %v\n", err)
+			}
+		}
+	}
+	// Hints that were removed take their unlocks with them
+	_, err := q.Exec("DELETE FROM hint_unlocks WHERE hint_id IN (SELECT id FROM hints WHERE challenge_id = ? AND position > ?)", challengeID, len(hints))
+	if err == nil {
+		_, err = q.Exec("DELETE FROM hints WHERE challenge_id = ? AND position > ?", challengeID, len(hints))
+	}
+	if err != nil {
+		log.Printf("Failed to remove hints: %v\n", err)
+	}
+}
+
+// getChallengeHints returns the hints of every challenge by challenge ID
+func getChallengeHints() (map[int][]Hint, error) {
+	rows, err := db.Query("SELECT id, challenge_id, position, content, cost FROM hints ORDER BY challenge_id, position")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hints := make(map[int][]Hint)
+	for rows.Next() {
+		var hint Hint
+		if err := rows.Scan(&hint.ID, &hint.ChallengeID, &hint.Position, &hint.Content, &hint.Cost); err != nil {
+			return nil, err
+		}
+		hints[hint.ChallengeID] = append(hints[hint.ChallengeID], hint)
+	}
+	return hints, rows.Err()
+}
+
+// GetHints returns the hints of a challenge in order, with the content of locked hints left out
+func GetHints(challengeID int, user *User) ([]Hint, error) {
+	rows, err := db.Query("SELECT id, challenge_id, position, content, cost FROM hints WHERE challenge_id = ? ORDER BY position", challengeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hints []Hint
+	for rows.Next() {
+		var hint Hint
+		if err := rows.Scan(&hint.ID, &hint.ChallengeID, &hint.Position, &hint.Content, &hint.Cost); err != nil {
+			return nil, err
+		}
+		hints = append(hints, hint)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range hints {
+		hints[i].Unlocked, err = hintUnlocked(hints[i].ID, user)
+		if err != nil {
+			return nil, err
+		}
+		if !hints[i].Unlocked {
+			hints[i].Content = ""
+		}
+	}
+	return hints, nil
+}
+
+// hintUnlocked reports whether the user's team (or the user, when solo) unlocked a hint.
+// Hints players unlocked while solo come with them to the team they join, which pays for them.
+func hintUnlocked(hintID int, user *User) (bool, error) {
+	var unlocked bool
+	var err error
+	if user.TeamID != nil {
+		err = db.QueryRow(`SELECT EXISTS(SELECT 1 FROM hint_unlocks hu JOIN users u ON hu.user_id = u.id
+			WHERE hu.hint_id = ? AND COALESCE(hu.team_id, u.team_id) = ?)`,
+			hintID, *user.TeamID).Scan(&unlocked)
+	} else {
+		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM hint_unlocks WHERE hint_id = ? AND user_id = ? AND team_id IS NULL)",
+			hintID, user.ID).Scan(&unlocked)
+	}
+	return unlocked, err
+}
+
+// UnlockHint unlocks a hint for the user's whole team. Hints have to be unlocked in order,
+// and only for challenges the user could submit a flag for.
+func UnlockHint(user *User, hintID int) error {
+	var challengeID, position int
+	err := db.QueryRow("SELECT challenge_id, position FROM hints WHERE id = ?", hintID).Scan(&challengeID, &position)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("hint not found")
+	}
+	if err != nil {
+		return err
+	}
+	_, _, teamID, err := openChallenge(challengeID, user.ID)
+	if err != nil {
+		return err
+	}
+	user = &User{ID: user.ID, TeamID: teamID}
+
+	unlocked, err := hintUnlocked(hintID, user)
+	if err != nil {
+		return err
+	}
+	if unlocked {
+		return errors.New("you have already unlocked this hint")
+	}
+
+	if position > 1 {
+		var previousID int
+		err := db.QueryRow("SELECT id FROM hints WHERE challenge_id = ? AND position = ?", challengeID, position-1).Scan(&previousID)
+		if err != nil {
+			return err
+		}
+		unlocked, err := hintUnlocked(previousID, user)
+		if err != nil {
+			return err
+		}
+		if !unlocked {
+			return errors.New("unlock the previous hint first")
+		}
+	}
+
+	// The unique indexes keep a teammate unlocking the hint at the same time from paying for it again
+	result, err := db.Exec("INSERT INTO hint_unlocks (hint_id, user_id, team_id) VALUES (?, ?, ?) ON CONFLICT DO NOTHING", hintID, user.ID, user.TeamID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return errors.New("you have already unlocked this hint")
+	}
+	invalidateScores()
+	events.Publish(events.ScoresChanged{})
+	return nil
+}
+
+// chargedUnlock picks, from hint_unlocks hu of users u, the unlocks that are paid for: the first
+// of each hint by each team or solo player, counting those teammates made before they joined
+const chargedUnlock = `NOT EXISTS (
+	SELECT 1 FROM hint_unlocks e JOIN users eu ON e.user_id = eu.id
+	WHERE e.hint_id = hu.hint_id AND e.id < hu.id
+		AND COALESCE(e.team_id, eu.team_id, -eu.id) = COALESCE(hu.team_id, u.team_id, -u.id)
+)`
+
+// getHintChanges returns the hints paid for before cutoff, as negative points
+func getHintChanges(cutoff string) ([]scoreChange, error) {
+	return queryScoreChanges(`
+		SELECT COALESCE(hu.team_id, u.team_id), hu.user_id, -h.cost, hu.timestamp
+		FROM hint_unlocks hu
+		JOIN hints h ON hu.hint_id = h.id
+		JOIN users u ON hu.user_id = u.id
+		WHERE h.cost > 0 AND hu.timestamp < ? AND `+chargedUnlock, cutoff)
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// maxLockout caps how long a team can be locked out of a challenge
+const maxLockout = time.Hour
+
+// LockoutError is returned by SubmitFlag while a team is locked out of a challenge for submitting too many wrong flags
+type LockoutError struct {
+	Until time.Time
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("too many wrong flags, try again in %s", time.Until(e.Until).Round(time.Second))
+}
+
+// GetLockout returns when the user's team (or the user, when solo) may submit flags for the challenge again, zero if it may now
+func GetLockout(user *User, challengeID int) (time.Time, error) {
+	until, _, err := lastLockout(user.ID, user.TeamID, challengeID)
+	if err != nil || !until.After(time.Now()) {
+		return time.Time{}, err
+	}
+	return until, nil
+}
+
+// lockoutEntry is the scoreboard ID lockouts apply to: the team, or the user when solo
+const lockoutEntry = "CASE WHEN team_id IS NULL THEN -user_id ELSE team_id END"
+
+// lastLockout returns when the latest lockout of a team from a challenge ends and how many it had so far
+func lastLockout(userID int, teamID *int, challengeID int) (time.Time, int, error) {
+	entry := -userID
+	if teamID != nil {
+		entry = *teamID
+	}
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM lockouts WHERE challenge_id = ? AND "+lockoutEntry+" = ?", challengeID, entry).Scan(&count)
+	if err != nil || count == 0 {
+		return time.Time{}, 0, err
+	}
+	var until time.Time
+	err = db.QueryRow("SELECT until FROM lockouts WHERE challenge_id = ? AND "+lockoutEntry+" = ? ORDER BY until DESC LIMIT 1", challengeID, entry).Scan(&until)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, 0, nil
+	}
+	return until, count, err
+}
+
+// checkLockout returns a LockoutError if the team is locked out of the challenge
+func checkLockout(userID int, teamID *int, challengeID int) error {
+	until, _, err := lastLockout(userID, teamID, challengeID)
+	if err != nil {
+		return err
+	}
+	if until.After(time.Now()) {
+		return &LockoutError{Until: until}
+	}
+	return nil
+}
+
+// recordWrongFlag locks the team out of the challenge if the wrong flag it just submitted used up its attempts
+func recordWrongFlag(userID int, teamID *int, challengeID int) {
+	if cfg.SubmitLimit <= 0 {
+		return
+	}
+	until, lockouts, err := lastLockout(userID, teamID, challengeID)
+	if err != nil {
+		log.Printf("Failed to look up lockouts: %v\n", err)
+		return
+	}
+	// Count the wrong flags in the window, but only those since the last lockout ended
+	since := time.Now().Add(-cfg.SubmitWindow)
+	if until.After(since) {
+		since = until
+	}
+	var wrong int
+	err = db.QueryRow(`
+		SELECT COUNT(*)
+		FROM submissions s
+		JOIN users u ON s.user_id = u.id
+		WHERE s.challenge_id = ? AND NOT s.correct AND s.timestamp >= ?
+			AND (u.id = ? OR u.team_id = ?)
+	`, challengeID, sqlTime(&since), userID, teamID).Scan(&wrong)
+	if err != nil {
+		log.Printf("Failed to count wrong flags: %v\n", err)
+		return
+	}
+	if wrong < cfg.SubmitLimit {
+		return
+	}
+
+	length := maxLockout
+	if lockouts < 16 {
+		length = min(cfg.Lockout<<lockouts, maxLockout)
+	}
+	lockedUntil := time.Now().Add(length)
+	_, err = db.Exec("INSERT INTO lockouts (user_id, team_id, challenge_id, until) VALUES (?, ?, ?, ?)",
+		userID, teamID, challengeID, sqlTime(&lockedUntil))
+	if err != nil {
+		log.Printf("Failed to insert lockout: %v\n", err)
+	}
+}
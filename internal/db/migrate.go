@@ -0,0 +1,109 @@
+package db
+
+import (
+	"embed"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migrations are numbered SQL files applied in order, each exactly once, with a
+// directory per dialect. Every migration is added to each dialect under the same
+// number, so a schema version means the same everywhere. Never edit one that has
+// been released; add a new one instead.
+//
+//go:embed migrations/*/*.sql
+var migrationFS embed.FS
+
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// loadMigrations returns the embedded migrations sorted by version
+func loadMigrations() ([]migration, error) {
+	dir := path.Join("migrations", db.dialect.name())
+	entries, err := migrationFS.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var migrations []migration
+	for _, entry := range entries {
+		prefix, _, _ := strings.Cut(entry.Name(), "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s does not start with a version number", entry.Name())
+		}
+		content, err := migrationFS.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{version, strings.TrimSuffix(entry.Name(), ".sql"), string(content)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	for i, m := range migrations {
+		if m.version != i+1 {
+			return nil, fmt.Errorf("migration %s is out of sequence, expected version %d", m.name, i+1)
+		}
+	}
+	return migrations, nil
+}
+
+// SchemaVersion returns the version the database schema was migrated to, 0 for a new database
+func SchemaVersion() (int, error) {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return 0, err
+	}
+	var version int
+	err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+	return version, err
+}
+
+// Migrate applies the migrations the database is missing and returns their names.
+// It refuses to touch a database migrated by a newer ctfsh.
+func Migrate() ([]string, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	current, err := SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if current > len(migrations) {
+		return nil, fmt.Errorf("database schema version %d is newer than this ctfsh supports (%d), upgrade ctfsh", current, len(migrations))
+	}
+
+	var applied []string
+	for _, m := range migrations[current:] {
+		if err := applyMigration(m); err != nil {
+			return applied, fmt.Errorf("migration %s: %w", m.name, err)
+		}
+		log.Printf("Applied migration %s\n", m.name)
+		applied = append(applied, m.name)
+	}
+	return applied, nil
+}
+
+func applyMigration(m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Rollback on error
+
+	if _, err := tx.Exec(m.sql); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO schema_version (version) VALUES (?)", m.version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,52 @@
+-- The schema of the first release, for PostgreSQL, brought up to date by the
+-- migrations that follow. Timestamps are stored in UTC like SQLite's
+-- CURRENT_TIMESTAMP. Foreign keys are left out, as SQLite never enforced them
+-- and deleting a team relies on that. SSH keys are stored as bytes from the
+-- start, see 016.
+
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	username TEXT UNIQUE NOT NULL,
+	ssh_key BYTEA NOT NULL UNIQUE,
+	team_id INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS teams (
+	id SERIAL PRIMARY KEY,
+	name TEXT UNIQUE NOT NULL,
+	score INTEGER DEFAULT 0,
+	join_code TEXT UNIQUE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS challenges (
+	id SERIAL PRIMARY KEY,
+	name TEXT UNIQUE NOT NULL,
+	title TEXT NOT NULL,
+	description TEXT NOT NULL,
+	category TEXT NOT NULL,
+	points INTEGER DEFAULT 0,
+	flag TEXT NOT NULL,
+	author TEXT NOT NULL,
+	build_dir TEXT
+);
+
+CREATE TABLE IF NOT EXISTS challenge_downloads (
+	id SERIAL PRIMARY KEY,
+	path TEXT NOT NULL,
+	challenge_id INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS challenge_ports (
+	id SERIAL PRIMARY KEY,
+	port INTEGER NOT NULL,
+	challenge_id INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS submissions (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	challenge_id INTEGER NOT NULL,
+	flag TEXT NOT NULL,
+	correct BOOLEAN NOT NULL,
+	timestamp TIMESTAMP DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+);
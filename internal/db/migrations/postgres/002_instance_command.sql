@@ -0,0 +1,2 @@
+-- Challenges can run an instance command.
+ALTER TABLE challenges ADD COLUMN command TEXT NOT NULL DEFAULT '';
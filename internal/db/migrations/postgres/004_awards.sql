@@ -0,0 +1,12 @@
+-- Bloods, and later admin adjustments, are kept as awards.
+CREATE TABLE awards (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER,
+	team_id INTEGER,
+	challenge_id INTEGER,
+	kind TEXT NOT NULL,
+	place INTEGER NOT NULL DEFAULT 0,
+	points INTEGER NOT NULL DEFAULT 0,
+	timestamp TIMESTAMP DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'),
+	UNIQUE(challenge_id, kind, place)
+);
@@ -0,0 +1,15 @@
+-- Per-team flags: submissions of another team's flag, and the settings table
+-- the generated flag secret is kept in.
+CREATE TABLE cheat_events (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	challenge_id INTEGER NOT NULL,
+	owner TEXT NOT NULL,
+	flag TEXT NOT NULL,
+	timestamp TIMESTAMP DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+);
+
+CREATE TABLE settings (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
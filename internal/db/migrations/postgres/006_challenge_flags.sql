@@ -0,0 +1,7 @@
+-- Challenges can accept several flags of different types.
+CREATE TABLE challenge_flags (
+	id SERIAL PRIMARY KEY,
+	challenge_id INTEGER NOT NULL,
+	type TEXT NOT NULL DEFAULT 'static',
+	flag TEXT NOT NULL
+);
@@ -0,0 +1,16 @@
+-- Ordered hints, which can cost points to unlock.
+CREATE TABLE hints (
+	id SERIAL PRIMARY KEY,
+	challenge_id INTEGER NOT NULL,
+	position INTEGER NOT NULL,
+	content TEXT NOT NULL,
+	cost INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE hint_unlocks (
+	id SERIAL PRIMARY KEY,
+	hint_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	team_id INTEGER,
+	timestamp TIMESTAMP DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+);
@@ -0,0 +1,8 @@
+-- Challenges can be locked until others are solved.
+CREATE TABLE challenge_requirements (
+	id SERIAL PRIMARY KEY,
+	challenge_id INTEGER NOT NULL,
+	required_challenge TEXT NOT NULL DEFAULT '',
+	category TEXT NOT NULL DEFAULT '',
+	points INTEGER NOT NULL DEFAULT 0
+);
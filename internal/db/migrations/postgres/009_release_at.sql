@@ -0,0 +1,2 @@
+-- Challenges can be released at a set time.
+ALTER TABLE challenges ADD COLUMN release_at TIMESTAMPTZ;
@@ -0,0 +1,5 @@
+-- Admins can ban players and teams, disable challenges and note why they adjusted a score.
+ALTER TABLE users ADD COLUMN banned BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE teams ADD COLUMN banned BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE challenges ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE awards ADD COLUMN note TEXT NOT NULL DEFAULT '';
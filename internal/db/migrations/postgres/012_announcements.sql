@@ -0,0 +1,7 @@
+-- Announcements from the organizers.
+CREATE TABLE announcements (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	message TEXT NOT NULL,
+	timestamp TIMESTAMP DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+);
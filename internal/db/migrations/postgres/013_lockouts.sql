@@ -0,0 +1,9 @@
+-- Lockouts after too many wrong flags.
+CREATE TABLE lockouts (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	team_id INTEGER,
+	challenge_id INTEGER NOT NULL,
+	until TIMESTAMP NOT NULL,
+	timestamp TIMESTAMP DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+);
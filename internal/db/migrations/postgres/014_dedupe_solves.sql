@@ -0,0 +1,12 @@
+-- Solves count once per team. Drop the correct submissions of teammates who
+-- solved a challenge after someone on their team already had.
+DELETE FROM submissions
+WHERE correct AND EXISTS (
+	SELECT 1
+	FROM submissions e
+	JOIN users eu ON e.user_id = eu.id
+	JOIN users u ON u.id = submissions.user_id
+	WHERE e.correct AND e.challenge_id = submissions.challenge_id
+		AND (e.timestamp < submissions.timestamp OR (e.timestamp = submissions.timestamp AND e.id < submissions.id))
+		AND CASE WHEN eu.team_id IS NULL THEN -eu.id ELSE eu.team_id END = CASE WHEN u.team_id IS NULL THEN -u.id ELSE u.team_id END
+);
@@ -0,0 +1,2 @@
+-- Public keys are passed as bytes, which PostgreSQL needs since they contain NUL
+-- bytes. They have been stored as BYTEA from the start, so there is nothing to do.
@@ -0,0 +1,12 @@
+-- A hint is unlocked, and paid for, once per team, or once per solo player.
+-- Drop the unlocks of teammates who unlocked a hint at the same time.
+DELETE FROM hint_unlocks
+WHERE EXISTS (
+	SELECT 1
+	FROM hint_unlocks e
+	WHERE e.hint_id = hint_unlocks.hint_id AND e.id < hint_unlocks.id
+		AND (e.team_id = hint_unlocks.team_id OR (e.team_id IS NULL AND hint_unlocks.team_id IS NULL AND e.user_id = hint_unlocks.user_id))
+);
+
+CREATE UNIQUE INDEX hint_unlocks_team ON hint_unlocks (hint_id, team_id);
+CREATE UNIQUE INDEX hint_unlocks_solo ON hint_unlocks (hint_id, user_id) WHERE team_id IS NULL;
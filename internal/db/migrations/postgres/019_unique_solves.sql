@@ -0,0 +1,17 @@
+-- A challenge is solved once per team, or once per solo player. Record who each
+-- submission counted for, so that a unique index can keep teammates who submit
+-- at the same time from both solving it. Drop any such solves that got in.
+ALTER TABLE submissions ADD COLUMN scoreboard_id INTEGER;
+
+UPDATE submissions
+SET scoreboard_id = (SELECT CASE WHEN u.team_id IS NULL THEN -u.id ELSE u.team_id END FROM users u WHERE u.id = submissions.user_id);
+
+DELETE FROM submissions
+WHERE correct AND EXISTS (
+	SELECT 1
+	FROM submissions e
+	WHERE e.correct AND e.challenge_id = submissions.challenge_id AND e.scoreboard_id = submissions.scoreboard_id
+		AND (e.timestamp < submissions.timestamp OR (e.timestamp = submissions.timestamp AND e.id < submissions.id))
+);
+
+CREATE UNIQUE INDEX submissions_solve ON submissions (scoreboard_id, challenge_id) WHERE correct;
@@ -0,0 +1,4 @@
+-- Challenge values can decay as more teams solve them.
+ALTER TABLE challenges ADD COLUMN decay_function TEXT NOT NULL DEFAULT 'static';
+ALTER TABLE challenges ADD COLUMN minimum_points INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE challenges ADD COLUMN decay_solves INTEGER NOT NULL DEFAULT 0;
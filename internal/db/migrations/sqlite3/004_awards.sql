@@ -0,0 +1,15 @@
+-- Bloods, and later admin adjustments, are kept as awards.
+CREATE TABLE awards (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER,
+	team_id INTEGER,
+	challenge_id INTEGER,
+	kind TEXT NOT NULL,
+	place INTEGER NOT NULL DEFAULT 0,
+	points INTEGER NOT NULL DEFAULT 0,
+	timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(challenge_id, kind, place),
+	FOREIGN KEY(user_id) REFERENCES users(id),
+	FOREIGN KEY(team_id) REFERENCES teams(id),
+	FOREIGN KEY(challenge_id) REFERENCES challenges(id)
+);
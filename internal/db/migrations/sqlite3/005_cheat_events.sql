@@ -0,0 +1,17 @@
+-- Per-team flags: submissions of another team's flag, and the settings table
+-- the generated flag secret is kept in.
+CREATE TABLE cheat_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	challenge_id INTEGER NOT NULL,
+	owner TEXT NOT NULL,
+	flag TEXT NOT NULL,
+	timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY(user_id) REFERENCES users(id),
+	FOREIGN KEY(challenge_id) REFERENCES challenges(id)
+);
+
+CREATE TABLE settings (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
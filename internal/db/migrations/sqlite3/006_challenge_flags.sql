@@ -0,0 +1,8 @@
+-- Challenges can accept several flags of different types.
+CREATE TABLE challenge_flags (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	challenge_id INTEGER NOT NULL,
+	type TEXT NOT NULL DEFAULT 'static',
+	flag TEXT NOT NULL,
+	FOREIGN KEY(challenge_id) REFERENCES challenges(id)
+);
@@ -0,0 +1,20 @@
+-- Ordered hints, which can cost points to unlock.
+CREATE TABLE hints (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	challenge_id INTEGER NOT NULL,
+	position INTEGER NOT NULL,
+	content TEXT NOT NULL,
+	cost INTEGER NOT NULL DEFAULT 0,
+	FOREIGN KEY(challenge_id) REFERENCES challenges(id)
+);
+
+CREATE TABLE hint_unlocks (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	hint_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	team_id INTEGER,
+	timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY(hint_id) REFERENCES hints(id),
+	FOREIGN KEY(user_id) REFERENCES users(id),
+	FOREIGN KEY(team_id) REFERENCES teams(id)
+);
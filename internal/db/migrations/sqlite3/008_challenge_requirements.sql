@@ -0,0 +1,9 @@
+-- Challenges can be locked until others are solved.
+CREATE TABLE challenge_requirements (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	challenge_id INTEGER NOT NULL,
+	required_challenge TEXT NOT NULL DEFAULT '',
+	category TEXT NOT NULL DEFAULT '',
+	points INTEGER NOT NULL DEFAULT 0,
+	FOREIGN KEY(challenge_id) REFERENCES challenges(id)
+);
@@ -0,0 +1,2 @@
+-- Challenges whose file is gone are hidden rather than deleted.
+ALTER TABLE challenges ADD COLUMN hidden BOOLEAN NOT NULL DEFAULT 0;
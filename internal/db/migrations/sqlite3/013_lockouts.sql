@@ -0,0 +1,12 @@
+-- Lockouts after too many wrong flags.
+CREATE TABLE lockouts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	team_id INTEGER,
+	challenge_id INTEGER NOT NULL,
+	until DATETIME NOT NULL,
+	timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY(user_id) REFERENCES users(id),
+	FOREIGN KEY(team_id) REFERENCES teams(id),
+	FOREIGN KEY(challenge_id) REFERENCES challenges(id)
+);
@@ -0,0 +1,2 @@
+-- Scores are computed from solves, awards and hints; this column was never updated.
+ALTER TABLE teams DROP COLUMN score;
@@ -0,0 +1,3 @@
+-- Public keys are passed as bytes, which PostgreSQL needs since they contain NUL
+-- bytes. SQLite never matches a blob against text, so convert the stored keys.
+UPDATE users SET ssh_key = CAST(ssh_key AS BLOB) WHERE typeof(ssh_key) = 'text';
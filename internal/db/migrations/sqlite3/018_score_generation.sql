@@ -0,0 +1,6 @@
+-- Counts changes to the scores, so that servers sharing the database know when the
+-- standings they keep in memory are out of date.
+CREATE TABLE score_generation (
+	generation INTEGER NOT NULL
+);
+INSERT INTO score_generation (generation) VALUES (0);
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"strconv"
+	"strings"
+
+	_ "github.com/lib/pq"
+)
+
+// postgres lets larger events share a PostgreSQL server
+type postgres struct{}
+
+func (postgres) name() string {
+	return "postgres"
+}
+
+// rebind numbers the placeholders as $1, $2, ..., leaving string literals and -- comments alone
+func (postgres) rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	quoted, comment := false, false
+	for i, r := range query {
+		switch {
+		case comment:
+			comment = r != '\n'
+		case r == '\'':
+			quoted = !quoted
+		case !quoted && r == '-' && strings.HasPrefix(query[i:], "--"):
+			comment = true
+		case !quoted && r == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// insert asks for the ID back, since the driver has no LastInsertId
+func (postgres) insert(q queryer, query string, args ...any) (int64, error) {
+	var id int64
+	err := q.QueryRow(query+" RETURNING id", args...).Scan(&id)
+	return id, err
+}
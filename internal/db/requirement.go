@@ -0,0 +1,144 @@
+package db
+
+import (
+	"fmt"
+	"log"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Requirement is a prerequisite for a challenge: either another challenge
+// solved, or at least Points scored in a Category
+type Requirement struct {
+	Challenge string `yaml:"challenge"`
+	Category  string `yaml:"category"`
+	Points    int    `yaml:"points"`
+}
+
+// UnmarshalYAML also accepts a plain challenge name
+func (r *Requirement) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&r.Challenge)
+	}
+	type plain Requirement
+	return node.Decode((*plain)(r))
+}
+
+func (r Requirement) String() string {
+	if r.Challenge != "" {
+		return r.Challenge
+	}
+	return fmt.Sprintf("%d pts in %s", r.Points, r.Category)
+}
+
+func createRequirements(q execer, challengeID int, reqs []Requirement) {
+	for _, req := range reqs {
+		_, err := q.Exec("INSERT INTO challenge_requirements (challenge_id, required_challenge, category, points) VALUES (?, ?, ?, ?)",
+			challengeID, req.Challenge, req.Category, req.Points)
+		if err != nil {
+			log.Printf("Failed to insert challenge requirement: %v\n", err)
+		}
+	}
+}
+
+// getChallengeRequirements returns the prerequisites of every challenge by challenge ID
+func getChallengeRequirements() (map[int][]Requirement, error) {
+	rows, err := db.Query("SELECT challenge_id, required_challenge, category, points FROM challenge_requirements ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reqs := make(map[int][]Requirement)
+	for rows.Next() {
+		var challengeID int
+		var req Requirement
+		if err := rows.Scan(&challengeID, &req.Challenge, &req.Category, &req.Points); err != nil {
+			return nil, err
+		}
+		reqs[challengeID] = append(reqs[challengeID], req)
+	}
+	return reqs, rows.Err()
+}
+
+// checkRequirements warns about prerequisites that can never be met
+func checkRequirements(challenges map[string]Challenge) {
+	categories := make(map[string]bool)
+	for _, chal := range challenges {
+		categories[chal.Category] = true
+	}
+	for _, chal := range challenges {
+		for _, req := range chal.Requires {
+			if req.Challenge != "" {
+				if _, ok := challenges[req.Challenge]; !ok {
+					log.Printf("Challenge %s requires unknown challenge %s\n", chal.Name, req.Challenge)
+				}
+			} else if !categories[req.Category] {
+				log.Printf("Challenge %s requires points in unknown category %s\n", chal.Name, req.Category)
+			}
+		}
+	}
+}
+
+// Progress is what a team (or solo player) has solved, for checking prerequisites
+type Progress struct {
+	solved         map[string]bool
+	categoryPoints map[string]int
+}
+
+// GetProgress returns the progress of the user's team, or of the user when solo
+func GetProgress(user *User) (*Progress, error) {
+	rows, err := db.Query(`
+		SELECT DISTINCT c.name, c.category, c.points
+		FROM submissions s
+		JOIN users u ON s.user_id = u.id
+		JOIN challenges c ON s.challenge_id = c.id
+		WHERE s.correct AND (u.id = ? OR u.team_id = ?)
+	`, user.ID, user.TeamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	p := &Progress{solved: make(map[string]bool), categoryPoints: make(map[string]int)}
+	for rows.Next() {
+		var name, category string
+		var points int
+		if err := rows.Scan(&name, &category, &points); err != nil {
+			return nil, err
+		}
+		p.solved[name] = true
+		p.categoryPoints[category] += points
+	}
+	return p, rows.Err()
+}
+
+// met reports whether a single prerequisite is met. Category thresholds
+// count initial points, so decaying values never lock a challenge again.
+func (p *Progress) met(req Requirement) bool {
+	if req.Challenge != "" {
+		return p.solved[req.Challenge]
+	}
+	return p.categoryPoints[req.Category] >= req.Points
+}
+
+// Unlocked reports whether all of the challenge's prerequisites are met
+func (p *Progress) Unlocked(chal Challenge) bool {
+	for _, req := range chal.Requires {
+		if !p.met(req) {
+			return false
+		}
+	}
+	return true
+}
+
+// MissingRequirements lists the prerequisites of the challenge that are not met yet
+func (p *Progress) MissingRequirements(chal Challenge) []string {
+	var missing []string
+	for _, req := range chal.Requires {
+		if !p.met(req) {
+			missing = append(missing, req.String())
+		}
+	}
+	return missing
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"ctfsh/internal/events"
+)
+
+var (
+	ErrEventNotStarted = errors.New("the CTF has not started yet")
+	ErrEventOver       = errors.New("the CTF is over")
+)
+
+// CheckEventOpen returns an error when flags can't be submitted because the CTF has not started or is over
+func CheckEventOpen() error {
+	now := time.Now()
+	if !cfg.EventStart.IsZero() && now.Before(cfg.EventStart) {
+		return ErrEventNotStarted
+	}
+	if !cfg.EventEnd.IsZero() && !now.Before(cfg.EventEnd) {
+		return ErrEventOver
+	}
+	return nil
+}
+
+// ReleaseTime returns when the challenge becomes visible: its own release time, but never before the CTF starts
+func (c Challenge) ReleaseTime() time.Time {
+	if c.ReleaseAt.After(cfg.EventStart) {
+		return c.ReleaseAt
+	}
+	return cfg.EventStart
+}
+
+// Released reports whether players can see the challenge yet
+func (c Challenge) Released() bool {
+	return !time.Now().Before(c.ReleaseTime())
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// noCutoff stands in for a missing cutoff, as it is later than any timestamp
+const noCutoff = "9999-12-31 23:59:59"
+
+// sqlTime formats a cutoff the way CURRENT_TIMESTAMP is stored, in UTC, or noCutoff if there is none
+func sqlTime(t *time.Time) string {
+	if t == nil {
+		return noCutoff
+	}
+	return t.UTC().Format(time.DateTime)
+}
+
+// ScoreboardFrozen reports whether players currently see the scoreboard as it was at the freeze
+func ScoreboardFrozen() bool {
+	if cfg.FreezeAt.IsZero() || time.Now().Before(cfg.FreezeAt) {
+		return false
+	}
+	revealed, err := getSetting("scoreboard_revealed")
+	return err != nil || revealed != "true"
+}
+
+// scoreboardCutoff returns the freeze time while the scoreboard is frozen, nil otherwise
+func scoreboardCutoff() *time.Time {
+	if !ScoreboardFrozen() {
+		return nil
+	}
+	return &cfg.FreezeAt
+}
+
+// RevealScoreboard unfreezes the scoreboard, showing players the final standings
+func RevealScoreboard() error {
+	err := setSetting("scoreboard_revealed", "true")
+	if err == nil {
+		events.Publish(events.ScoresChanged{})
+	}
+	return err
+}
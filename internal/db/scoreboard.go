@@ -1,64 +1,289 @@
 package db
 
+import (
+	"database/sql"
+	"log"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// GetScoreboard returns the standings players see, which leave out banned teams and stop changing while the scoreboard is frozen
 func GetScoreboard() ([]Team, error) {
-	// Get teams and their scores
+	teams, err := scoreboard(scoreboardCutoff())
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(teams, func(t Team) bool { return t.Banned }), nil
+}
+
+// GetLiveScoreboard returns the current standings of every team, even while the scoreboard is frozen
+func GetLiveScoreboard() ([]Team, error) {
+	return scoreboard(nil)
+}
+
+// The standings are kept in memory so that sessions refreshing the scoreboard don't
+// each sum up every submission. Correct flags are added to the live standings as they
+// come in; anything else that changes scores calls invalidateScores to reload them.
+// Every change also bumps the score_generation row, so that other servers sharing the
+// database reload their standings too.
+var scoreCache struct {
+	sync.Mutex
+	generation int64 // of score_generation the standings are up to date with
+	live       *standings
+	frozen     *standings // as of the freeze, which solves coming in can't change
+}
+
+// scoreboard returns the standings counting what happened before until, or everything if it is nil
+func scoreboard(until *time.Time) ([]Team, error) {
+	scoreCache.Lock()
+	defer scoreCache.Unlock()
+
+	var generation int64
+	if err := db.QueryRow("SELECT generation FROM score_generation").Scan(&generation); err != nil {
+		return nil, err
+	}
+	if generation != scoreCache.generation {
+		scoreCache.generation = generation
+		scoreCache.live = nil
+		scoreCache.frozen = nil
+	}
+	cached := &scoreCache.live
+	if until != nil {
+		cached = &scoreCache.frozen
+	}
+	if *cached == nil {
+		s, err := loadStandings(until)
+		if err != nil {
+			return nil, err
+		}
+		*cached = s
+	}
+	return (*cached).teams(), nil
+}
+
+// invalidateScores makes the next scoreboard read the standings from the database again, on every server
+func invalidateScores() {
+	if _, err := bumpScoreGeneration(); err != nil {
+		log.Printf("Failed to bump the score generation: %v\n", err)
+	}
+	forgetScores()
+}
+
+// forgetScores drops the standings kept by this server
+func forgetScores() {
+	scoreCache.Lock()
+	defer scoreCache.Unlock()
+	scoreCache.generation = -1
+	scoreCache.live = nil
+	scoreCache.frozen = nil
+}
+
+// bumpScoreGeneration records a change to the scores and returns the new generation
+func bumpScoreGeneration() (int64, error) {
+	if _, err := db.Exec("UPDATE score_generation SET generation = generation + 1"); err != nil {
+		return 0, err
+	}
+	var generation int64
+	err := db.QueryRow("SELECT generation FROM score_generation").Scan(&generation)
+	return generation, err
+}
+
+// cacheSolve adds a correct flag that was just submitted, and the blood bonus it earned, to the live standings
+func cacheSolve(submissionID int64, scoreboardID, challengeID, bonus int) {
+	// Ties are broken by solve times, so use the one stored rather than the clock here
+	var at time.Time
+	err := db.QueryRow("SELECT timestamp FROM submissions WHERE id = ?", submissionID).Scan(&at)
+	if err != nil {
+		log.Printf("Failed to read solve time: %v\n", err)
+		invalidateScores()
+		return
+	}
+	generation, err := bumpScoreGeneration()
+	if err != nil {
+		log.Printf("Failed to bump the score generation: %v\n", err)
+		forgetScores()
+		return
+	}
+
+	scoreCache.Lock()
+	defer scoreCache.Unlock()
+	s := scoreCache.live
+	if s == nil {
+		return
+	}
+	if generation != scoreCache.generation+1 {
+		// Scores changed elsewhere too, maybe on another server
+		scoreCache.live = nil
+		return
+	}
+	scoreCache.generation = generation
+	if _, ok := s.solvedAt[scoreboardID]; !ok || s.solved(scoreboardID, challengeID) {
+		// Reloaded since the flag was submitted, or the entry is new: start over rather than guess
+		scoreCache.live = nil
+		return
+	}
+	s.solvedAt[scoreboardID][challengeID] = at
+	s.solves[challengeID]++
+	s.adjustments[scoreboardID] += bonus
+}
+
+// standings is everything scores are summed from. Solves count at the challenge's current
+// value, on top of any awards and minus hint costs.
+type standings struct {
+	entries     []Team                    // teams, then solo players by negative ID, without scores
+	adjustments map[int]int               // awards minus hint costs, by scoreboard ID
+	adjustedAt  map[int]time.Time         // when the latest award or hint cost was, by scoreboard ID
+	solvedAt    map[int]map[int]time.Time // when each entry first solved each challenge, by scoreboard ID and challenge ID
+	solves      map[int]int               // how many entries solved each challenge
+	challenges  map[int]Challenge         // the scoring settings of every challenge
+}
+
+func (s *standings) solved(scoreboardID, challengeID int) bool {
+	_, ok := s.solvedAt[scoreboardID][challengeID]
+	return ok
+}
+
+// teams returns the entries with their scores, teams first and each ranked by score.
+// Ties go to whoever reached their score first, going by the last change to it.
+func (s *standings) teams() []Team {
+	values := make(map[int]int, len(s.challenges))
+	for id, chal := range s.challenges {
+		values[id] = chal.ValueAt(s.solves[id])
+	}
+
+	teams := slices.Clone(s.entries)
+	for i := range teams {
+		t := &teams[i]
+		t.Score = s.adjustments[t.ID]
+		t.LastChange = s.adjustedAt[t.ID]
+		for challengeID, at := range s.solvedAt[t.ID] {
+			t.Score += values[challengeID]
+			if at.After(t.LastChange) {
+				t.LastChange = at
+			}
+		}
+	}
+	sort.SliceStable(teams, func(i, j int) bool {
+		a, b := teams[i], teams[j]
+		if (a.ID > 0) != (b.ID > 0) {
+			return a.ID > 0
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if !a.LastChange.Equal(b.LastChange) {
+			// Entries whose score never changed go last
+			return !a.LastChange.IsZero() && (b.LastChange.IsZero() || a.LastChange.Before(b.LastChange))
+		}
+		return a.Name < b.Name
+	})
+	return teams
+}
+
+// loadStandings reads the standings as of until, or now if it is nil, from the database
+func loadStandings(until *time.Time) (*standings, error) {
+	challenges, err := getScoringChallenges()
+	if err != nil {
+		return nil, err
+	}
+	s := &standings{
+		adjustments: make(map[int]int),
+		adjustedAt:  make(map[int]time.Time),
+		solvedAt:    make(map[int]map[int]time.Time),
+		solves:      make(map[int]int),
+		challenges:  challenges,
+	}
+
 	rows, err := db.Query(`
-		SELECT t.id, t.name, COALESCE(SUM(c.points), 0) as score, COUNT(u.id) as player_count
+		SELECT t.id, t.name, t.banned, COUNT(u.id) as player_count
 		FROM teams t
 		LEFT JOIN users u ON t.id = u.team_id
-		LEFT JOIN (
-			SELECT s.user_id, s.challenge_id
-			FROM submissions s
-			WHERE s.correct = 1
-			GROUP BY s.user_id, s.challenge_id
-		) as solved_challs ON u.id = solved_challs.user_id
-		LEFT JOIN challenges c ON solved_challs.challenge_id = c.id
-		GROUP BY t.id, t.name
-		ORDER BY score DESC, t.name ASC
+		GROUP BY t.id, t.name, t.banned
 	`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-
-	var teams []Team
 	for rows.Next() {
 		var team Team
-		if err := rows.Scan(&team.ID, &team.Name, &team.Score, &team.PlayerCount); err != nil {
+		if err := rows.Scan(&team.ID, &team.Name, &team.Banned, &team.PlayerCount); err != nil {
 			return nil, err
 		}
-		teams = append(teams, team)
+		s.entries = append(s.entries, team)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
 	// Add solo users (users with no team) as their own 'team'
-	userRows, err := db.Query(`
-		SELECT u.id, u.username, COALESCE(SUM(c.points), 0) as score
-		FROM users u
-		LEFT JOIN (
-			SELECT s.user_id, s.challenge_id
-			FROM submissions s
-			WHERE s.correct = 1
-			GROUP BY s.user_id, s.challenge_id
-		) as solved_challs ON u.id = solved_challs.user_id
-		LEFT JOIN challenges c ON solved_challs.challenge_id = c.id
-		WHERE u.team_id IS NULL
-		GROUP BY u.id, u.username
-		ORDER BY score DESC, u.username ASC
-	`)
+	userRows, err := db.Query("SELECT id, username, banned FROM users WHERE team_id IS NULL")
 	if err != nil {
 		return nil, err
 	}
 	defer userRows.Close()
-
 	for userRows.Next() {
 		var id int
 		var username string
-		var score int
-		if err := userRows.Scan(&id, &username, &score); err != nil {
+		var banned bool
+		if err := userRows.Scan(&id, &username, &banned); err != nil {
 			return nil, err
 		}
-		teams = append(teams, Team{ID: -id, Name: username, Score: score, PlayerCount: 1}) // negative ID to distinguish solo
+		s.entries = append(s.entries, Team{ID: -id, Name: username, PlayerCount: 1, Banned: banned}) // negative ID to distinguish solo
 	}
+	if err := userRows.Err(); err != nil {
+		return nil, err
+	}
+	for _, entry := range s.entries {
+		s.solvedAt[entry.ID] = make(map[int]time.Time)
+	}
+
+	cutoff := sqlTime(until)
+	awards, err := getAwardChanges(cutoff)
+	if err != nil {
+		return nil, err
+	}
+	hints, err := getHintChanges(cutoff)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range append(awards, hints...) {
+		s.adjustments[c.id] += c.points
+		if c.time.After(s.adjustedAt[c.id]) {
+			s.adjustedAt[c.id] = c.time
+		}
+	}
+
+	solveRows, err := db.Query(`
+		SELECT s.user_id, u.team_id, s.challenge_id, s.timestamp
+		FROM submissions s
+		JOIN users u ON s.user_id = u.id
+		WHERE s.correct AND s.timestamp < ?
+		ORDER BY s.timestamp, s.id
+	`, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer solveRows.Close()
 
-	return teams, nil
+	// Teammates can't solve a challenge twice, but a player who joined a team may bring a solve the team already had
+	for solveRows.Next() {
+		var userID, challengeID int
+		var teamID sql.NullInt64
+		var timestamp time.Time
+		if err := solveRows.Scan(&userID, &teamID, &challengeID, &timestamp); err != nil {
+			return nil, err
+		}
+		id := scoreboardID(userID, teamID)
+		if s.solved(id, challengeID) {
+			continue
+		}
+		if s.solvedAt[id] == nil {
+			s.solvedAt[id] = make(map[int]time.Time)
+		}
+		s.solvedAt[id][challengeID] = timestamp
+		s.solves[challengeID]++
+	}
+	return s, solveRows.Err()
 }
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"math"
+	"time"
+)
+
+// Decay functions for dynamic challenge scoring
+const (
+	DecayStatic      = "static"
+	DecayLinear      = "linear"
+	DecayLogarithmic = "logarithmic"
+	DecayParabolic   = "parabolic"
+)
+
+func validDecayFunction(function string) bool {
+	switch function {
+	case DecayStatic, DecayLinear, DecayLogarithmic, DecayParabolic:
+		return true
+	}
+	return false
+}
+
+// Dynamic reports whether the challenge's value changes with its solve count
+func (c Challenge) Dynamic() bool {
+	return c.DecayFunction != "" && c.DecayFunction != DecayStatic && c.DecaySolves > 0
+}
+
+// ValueAt returns what the challenge is worth once it has been solved the given number of times.
+// The first solve keeps the full points, and the value reaches the minimum after DecaySolves more.
+func (c Challenge) ValueAt(solves int) int {
+	if !c.Dynamic() || solves <= 1 {
+		return c.Points
+	}
+	n := float64(solves - 1)
+	decay := float64(c.DecaySolves)
+	span := float64(c.Points - c.MinimumPoints)
+
+	var value float64
+	switch c.DecayFunction {
+	case DecayLinear:
+		value = float64(c.Points) - span*n/decay
+	case DecayLogarithmic:
+		value = float64(c.Points) - span*math.Log1p(n)/math.Log1p(decay)
+	case DecayParabolic:
+		// Same curve as CTFd's dynamic challenges
+		value = float64(c.Points) - span*n*n/(decay*decay)
+	}
+	return max(int(math.Ceil(value)), c.MinimumPoints)
+}
+
+// getSolveCounts returns how many teams (or solo players) solved each challenge before until
+func getSolveCounts(until *time.Time) (map[int]int, error) {
+	cutoff := sqlTime(until)
+	rows, err := db.Query(`
+		SELECT s.challenge_id, COUNT(DISTINCT CASE WHEN u.team_id IS NULL THEN -u.id ELSE u.team_id END)
+		FROM submissions s
+		JOIN users u ON s.user_id = u.id
+		WHERE s.correct AND s.timestamp < ?
+		GROUP BY s.challenge_id
+	`, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var challengeID, count int
+		if err := rows.Scan(&challengeID, &count); err != nil {
+			return nil, err
+		}
+		counts[challengeID] = count
+	}
+	return counts, rows.Err()
+}
+
+// getChallengeValues returns the value of every challenge by ID as of until
+func getChallengeValues(until *time.Time) (map[int]int, error) {
+	solves, err := getSolveCounts(until)
+	if err != nil {
+		return nil, err
+	}
+	challenges, err := getScoringChallenges()
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[int]int)
+	for id, chal := range challenges {
+		values[id] = chal.ValueAt(solves[id])
+	}
+	return values, nil
+}
+
+// getScoringChallenges returns every challenge by ID with just what ValueAt needs
+func getScoringChallenges() (map[int]Challenge, error) {
+	rows, err := db.Query("SELECT id, points, decay_function, minimum_points, decay_solves FROM challenges")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	challenges := make(map[int]Challenge)
+	for rows.Next() {
+		var chal Challenge
+		if err := rows.Scan(&chal.ID, &chal.Points, &chal.DecayFunction, &chal.MinimumPoints, &chal.DecaySolves); err != nil {
+			return nil, err
+		}
+		challenges[chal.ID] = chal
+	}
+	return challenges, rows.Err()
+}
@@ -0,0 +1,13 @@
+package db
+
+// getSetting returns a value the server keeps in the database, or sql.ErrNoRows if it was never set
+func getSetting(key string) (string, error) {
+	var value string
+	err := db.QueryRow("SELECT value FROM settings WHERE key = ?", key).Scan(&value)
+	return value, err
+}
+
+func setSetting(key, value string) error {
+	_, err := db.Exec("INSERT INTO settings (key, value) VALUES (?, ?) ON CONFLICT (key) DO UPDATE SET value = excluded.value", key, value)
+	return err
+}
@@ -0,0 +1,31 @@
+package db
+
+import (
+	"database/sql"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqlite keeps everything in a single file, which is plenty for most events
+type sqlite struct{}
+
+func (sqlite) name() string {
+	return "sqlite3"
+}
+
+func (sqlite) rebind(query string) string {
+	return query
+}
+
+func (sqlite) insert(q queryer, query string, args ...any) (int64, error) {
+	result, err := q.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, sql.ErrNoRows // as RETURNING gives on PostgreSQL
+	}
+	return result.LastInsertId()
+}
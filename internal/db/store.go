@@ -0,0 +1,110 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// dialect is what differs between the databases ctfsh can keep its data in.
+// Queries are written once, with ? placeholders, in SQL both understand.
+type dialect interface {
+	// name is the database/sql driver, and the directory of its migrations
+	name() string
+	// rebind rewrites the ? placeholders of a query for the driver
+	rebind(query string) string
+	// insert runs an INSERT and returns the ID of the new row, or sql.ErrNoRows
+	// if ON CONFLICT DO NOTHING skipped it
+	insert(q queryer, query string, args ...any) (int64, error)
+}
+
+// queryer is what a *sql.DB and a *sql.Tx have in common
+type queryer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// dialectFor picks the database a DSN points to: a postgres:// URL, or else the path of an SQLite file
+func dialectFor(dsn string) dialect {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return postgres{}
+	}
+	return sqlite{}
+}
+
+// execer writes through a store, or a tx when several writes have to land together
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Insert(query string, args ...any) (int64, error)
+}
+
+// store runs queries against the database in its dialect
+type store struct {
+	conn    *sql.DB
+	dialect dialect
+}
+
+func openStore(dsn string) (*store, error) {
+	d := dialectFor(dsn)
+	conn, err := sql.Open(d.name(), dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &store{conn, d}, nil
+}
+
+func (s *store) Exec(query string, args ...any) (sql.Result, error) {
+	return s.conn.Exec(s.dialect.rebind(query), args...)
+}
+
+func (s *store) Query(query string, args ...any) (*sql.Rows, error) {
+	return s.conn.Query(s.dialect.rebind(query), args...)
+}
+
+func (s *store) QueryRow(query string, args ...any) *sql.Row {
+	return s.conn.QueryRow(s.dialect.rebind(query), args...)
+}
+
+// Insert runs an INSERT and returns the ID of the new row
+func (s *store) Insert(query string, args ...any) (int64, error) {
+	return s.dialect.insert(s.conn, s.dialect.rebind(query), args...)
+}
+
+func (s *store) Begin() (*tx, error) {
+	t, err := s.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &tx{t, s.dialect}, nil
+}
+
+func (s *store) Close() error {
+	return s.conn.Close()
+}
+
+// tx is a transaction on a store
+type tx struct {
+	tx      *sql.Tx
+	dialect dialect
+}
+
+func (t *tx) Exec(query string, args ...any) (sql.Result, error) {
+	return t.tx.Exec(t.dialect.rebind(query), args...)
+}
+
+func (t *tx) QueryRow(query string, args ...any) *sql.Row {
+	return t.tx.QueryRow(t.dialect.rebind(query), args...)
+}
+
+// Insert runs an INSERT and returns the ID of the new row
+func (t *tx) Insert(query string, args ...any) (int64, error) {
+	return t.dialect.insert(t.tx, t.dialect.rebind(query), args...)
+}
+
+func (t *tx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *tx) Rollback() error {
+	return t.tx.Rollback()
+}
@@ -1,9 +1,14 @@
 package db
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
+
+	"ctfsh/internal/events"
 )
 
 type Submission struct {
@@ -13,32 +18,153 @@ type Submission struct {
 	Flag        string
 	Correct     bool
 	Timestamp   time.Time
+
+	Username  string
+	Challenge string
+}
+
+// GetSubmissions returns the latest submissions of everyone, newest first
+func GetSubmissions(limit int) ([]Submission, error) {
+	rows, err := db.Query(`
+		SELECT s.id, s.user_id, s.challenge_id, s.flag, s.correct, s.timestamp, u.username, c.name
+		FROM submissions s
+		JOIN users u ON s.user_id = u.id
+		JOIN challenges c ON s.challenge_id = c.id
+		ORDER BY s.timestamp DESC, s.id DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var submissions []Submission
+	for rows.Next() {
+		var sub Submission
+		if err := rows.Scan(&sub.ID, &sub.UserID, &sub.ChallengeID, &sub.Flag, &sub.Correct, &sub.Timestamp, &sub.Username, &sub.Challenge); err != nil {
+			return nil, err
+		}
+		submissions = append(submissions, sub)
+	}
+	return submissions, rows.Err()
+}
+
+// openChallenge loads a challenge for a player to work on, as long as the event is running,
+// they aren't banned and the challenge is visible, released and unlocked by its prerequisites.
+// It also returns the player's name and current team.
+func openChallenge(challengeID, userID int) (chal Challenge, username string, teamID *int, err error) {
+	if err := CheckEventOpen(); err != nil {
+		return chal, "", nil, err
+	}
+	var releaseAt sql.NullTime
+	err = db.QueryRow("SELECT id, name, flag, release_at, hidden, disabled FROM challenges WHERE id = ?", challengeID).Scan(&chal.ID, &chal.Name, &chal.Flag, &releaseAt, &chal.Hidden, &chal.Disabled)
+	if err != nil {
+		return chal, "", nil, err
+	}
+	chal.ReleaseAt = releaseAt.Time
+	if chal.Hidden || chal.Disabled {
+		return chal, "", nil, fmt.Errorf("this challenge has been removed")
+	}
+	if !chal.Released() {
+		return chal, "", nil, fmt.Errorf("this challenge has not been released yet")
+	}
+	var banned bool
+	err = db.QueryRow("SELECT u.username, u.team_id, u.banned OR COALESCE(t.banned, FALSE) FROM users u LEFT JOIN teams t ON u.team_id = t.id WHERE u.id = ?", userID).Scan(&username, &teamID, &banned)
+	if err != nil {
+		return chal, "", nil, err
+	}
+	if banned {
+		return chal, "", nil, fmt.Errorf("you have been banned")
+	}
+
+	reqs, err := getChallengeRequirements()
+	if err != nil {
+		return chal, "", nil, err
+	}
+	chal.Requires = reqs[chal.ID]
+	progress, err := GetProgress(&User{ID: userID, TeamID: teamID})
+	if err != nil {
+		return chal, "", nil, err
+	}
+	if !progress.Unlocked(chal) {
+		return chal, "", nil, fmt.Errorf("this challenge is locked")
+	}
+	return chal, username, teamID, nil
 }
 
 func SubmitFlag(userID, challengeID int, flag string) (bool, error) {
-	var correctFlag string
-	err := db.QueryRow("SELECT flag FROM challenges WHERE id = ?", challengeID).Scan(&correctFlag)
+	chal, username, teamID, err := openChallenge(challengeID, userID)
+	if err != nil {
+		return false, err
+	}
+	flags, err := getFlags(chal.ID)
 	if err != nil {
 		return false, err
 	}
+	chal.setFlags(flags)
 
-	correct := strings.TrimSpace(flag) == strings.TrimSpace(correctFlag)
+	correct := matchesFlag(chal, flagOwner(userID, teamID), strings.TrimSpace(flag))
 
-	var alreadySolved bool
-	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM submissions WHERE user_id = ? AND challenge_id = ? AND correct = 1)",
-		userID, challengeID).Scan(&alreadySolved)
-	if err != nil {
+	// Solves count for the whole team, so only the first one is recorded
+	if err := checkSolved(userID, teamID, challengeID, username); err != nil {
+		return false, err
+	}
+	if err := checkLockout(userID, teamID, challengeID); err != nil {
 		return false, err
 	}
 
-	if alreadySolved {
-		return false, fmt.Errorf("you have already solved this challenge")
+	id := -userID
+	if teamID != nil {
+		id = *teamID
+	}
+	// The unique index on correct solves keeps a teammate submitting at the same time from solving it twice
+	submissionID, err := db.Insert("INSERT INTO submissions (user_id, challenge_id, flag, correct, scoreboard_id) VALUES (?, ?, ?, ?, ?) ON CONFLICT DO NOTHING",
+		userID, challengeID, flag, correct, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		if err := checkSolved(userID, teamID, challengeID, username); err != nil {
+			return false, err
+		}
+		return false, fmt.Errorf("this challenge was already solved by your team")
+	}
+	if err != nil {
+		return false, err
 	}
 
-	_, err = db.Exec("INSERT INTO submissions (user_id, challenge_id, flag, correct) VALUES (?, ?, ?, ?)",
-		userID, challengeID, flag, correct)
+	if correct {
+		bonus := recordBlood(submissionID, userID, teamID, challengeID)
+		cacheSolve(submissionID, id, challengeID, bonus)
+		events.Publish(events.Solve{UserID: userID, Username: username, TeamID: teamID, Challenge: chal.Name})
+	} else {
+		recordWrongFlag(userID, teamID, challengeID)
+	}
+	if !correct && chal.PerTeam() {
+		owner, shared, err := findFlagOwner(chal, strings.TrimSpace(flag), userID, teamID)
+		if err != nil {
+			log.Printf("Failed to check for a shared flag: %v\n", err)
+		} else if shared {
+			log.Printf("User %d submitted the flag of %s for challenge %s\n", userID, owner, chal.Name)
+			recordCheatEvent(userID, challengeID, owner, flag)
+		}
+	}
+	return correct, nil
+}
 
-	return correct, err
+// checkSolved returns an error naming who solved the challenge if the user or their team already did
+func checkSolved(userID int, teamID *int, challengeID int, username string) error {
+	var solver string
+	err := db.QueryRow(`SELECT u.username FROM submissions s JOIN users u ON s.user_id = u.id
+		WHERE s.challenge_id = ? AND s.correct AND (u.id = ? OR u.team_id = ?)
+		ORDER BY s.timestamp, s.id LIMIT 1`, challengeID, userID, teamID).Scan(&solver)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if solver == username {
+		return fmt.Errorf("you have already solved this challenge")
+	}
+	return fmt.Errorf("this challenge was already solved by %s", solver)
 }
 
 // Returns a map of challenge_id to username for the first solver on the team
@@ -47,9 +173,8 @@ func GetTeamChallengeSolvers(teamID int) (map[int]string, error) {
 	SELECT s.challenge_id, u.username
 	FROM submissions s
 	JOIN users u ON s.user_id = u.id
-	WHERE u.team_id = ? AND s.correct = 1
-	GROUP BY s.challenge_id
-	ORDER BY s.timestamp ASC
+	WHERE u.team_id = ? AND s.correct
+	ORDER BY s.timestamp DESC, s.id DESC
 	`
 	rows, err := db.Query(query, teamID)
 	if err != nil {
@@ -57,6 +182,7 @@ func GetTeamChallengeSolvers(teamID int) (map[int]string, error) {
 	}
 	defer rows.Close()
 
+	// Newest first, so the first solver of each challenge is the one left in the map
 	solvers := make(map[int]string)
 	for rows.Next() {
 		var challengeID int
@@ -68,3 +194,84 @@ func GetTeamChallengeSolvers(teamID int) (map[int]string, error) {
 	}
 	return solvers, nil
 }
+
+// GetSolvedChallenges returns the challenges solved by the user's team, or by the user when solo
+func GetSolvedChallenges(user *User) (map[int]bool, error) {
+	rows, err := db.Query(`
+		SELECT DISTINCT s.challenge_id
+		FROM submissions s
+		JOIN users u ON s.user_id = u.id
+		WHERE s.correct AND (u.id = ? OR u.team_id = ?)
+	`, user.ID, user.TeamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	solved := make(map[int]bool)
+	for rows.Next() {
+		var challengeID int
+		if err := rows.Scan(&challengeID); err != nil {
+			return nil, err
+		}
+		solved[challengeID] = true
+	}
+	return solved, rows.Err()
+}
+
+// Solve is a challenge solved by a player, worth the challenge's current value
+type Solve struct {
+	ChallengeID int
+	Challenge   string
+	Category    string
+	Username    string
+	Points      int
+	Timestamp   time.Time
+}
+
+// GetSolves returns the solves of a scoreboard entry (a team, or a solo player by negative ID) as players see them, oldest first
+func GetSolves(scoreboardID int) ([]Solve, error) {
+	return solves(scoreboardID, scoreboardCutoff())
+}
+
+// GetLiveSolves is GetSolves ignoring the freeze
+func GetLiveSolves(scoreboardID int) ([]Solve, error) {
+	return solves(scoreboardID, nil)
+}
+
+func solves(scoreboardID int, until *time.Time) ([]Solve, error) {
+	values, err := getChallengeValues(until)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := sqlTime(until)
+	rows, err := db.Query(`
+		SELECT c.id, c.name, c.category, u.username, s.timestamp
+		FROM submissions s
+		JOIN users u ON s.user_id = u.id
+		JOIN challenges c ON s.challenge_id = c.id
+		WHERE s.correct AND s.timestamp < ?
+			AND CASE WHEN u.team_id IS NULL THEN -u.id ELSE u.team_id END = ?
+		ORDER BY s.timestamp, s.id
+	`, cutoff, scoreboardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var solves []Solve
+	seen := make(map[int]bool)
+	for rows.Next() {
+		var solve Solve
+		if err := rows.Scan(&solve.ChallengeID, &solve.Challenge, &solve.Category, &solve.Username, &solve.Timestamp); err != nil {
+			return nil, err
+		}
+		if seen[solve.ChallengeID] {
+			continue
+		}
+		seen[solve.ChallengeID] = true
+		solve.Points = values[solve.ChallengeID]
+		solves = append(solves, solve)
+	}
+	return solves, rows.Err()
+}
@@ -3,6 +3,9 @@ package db
 import (
 	"fmt"
 	"math/rand/v2"
+	"time"
+
+	"ctfsh/internal/events"
 )
 
 type Team struct {
@@ -11,6 +14,8 @@ type Team struct {
 	Score       int
 	PlayerCount int
 	JoinCode    string
+	Banned      bool
+	LastChange  time.Time // when the score last changed, which breaks ties on the scoreboard; zero if it never did
 }
 
 func GetTeamNameAndCode(teamID int) (string, string, error) {
@@ -21,8 +26,8 @@ func GetTeamNameAndCode(teamID int) (string, string, error) {
 
 func GetTeamByJoinCode(code string) (*Team, error) {
 	team := &Team{}
-	err := db.QueryRow("SELECT id, name, score, join_code FROM teams WHERE join_code = ?", code).
-		Scan(&team.ID, &team.Name, &team.Score, &team.JoinCode)
+	err := db.QueryRow("SELECT id, name, join_code FROM teams WHERE join_code = ?", code).
+		Scan(&team.ID, &team.Name, &team.JoinCode)
 	if err != nil {
 		return nil, err
 	}
@@ -53,16 +58,11 @@ func CreateAndJoinTeam(creatorID int, teamName string) (*Team, error) {
 	defer tx.Rollback() // Rollback on error
 
 	joinCode := GenerateJoinCode()
-	res, err := tx.Exec("INSERT INTO teams (name, join_code) VALUES (?, ?)", teamName, joinCode)
+	id, err := tx.Insert("INSERT INTO teams (name, join_code) VALUES (?, ?)", teamName, joinCode)
 	if err != nil {
 		return nil, fmt.Errorf("team name likely already exists")
 	}
 
-	id, err := res.LastInsertId()
-	if err != nil {
-		return nil, err
-	}
-
 	_, err = tx.Exec("UPDATE users SET team_id = ? WHERE id = ?", id, creatorID)
 	if err != nil {
 		return nil, err
@@ -71,6 +71,7 @@ func CreateAndJoinTeam(creatorID int, teamName string) (*Team, error) {
 	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
+	invalidateScores()
 
 	return &Team{ID: int(id), Name: teamName}, nil
 }
@@ -86,11 +87,13 @@ func JoinTeam(userID int, teamName string) (int, error) {
 	if err != nil {
 		return 0, err
 	}
+	invalidateScores()
 	return teamID, nil
 }
 
 func LeaveTeam(userID int) error {
 	_, err := db.Exec("UPDATE users SET team_id = NULL WHERE id = ?", userID)
+	invalidateScores()
 	return err
 }
 
@@ -118,6 +121,64 @@ func GetTeamMembers(teamID int) ([]User, error) {
 	return users, nil
 }
 
+// GetTeamMemberPoints returns what each member contributed to the team's score by user ID:
+// the challenges they solved first on the team, their awards and minus the hints they unlocked.
+// While the scoreboard is frozen it counts only what happened before the freeze.
+func GetTeamMemberPoints(teamID int) (map[int]int, error) {
+	until := scoreboardCutoff()
+	values, err := getChallengeValues(until)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := sqlTime(until)
+	rows, err := db.Query(`
+		SELECT s.user_id, s.challenge_id
+		FROM submissions s
+		JOIN users u ON s.user_id = u.id
+		WHERE u.team_id = ? AND s.correct AND s.timestamp < ?
+		ORDER BY s.timestamp, s.id
+	`, teamID, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := make(map[int]int)
+	solved := make(map[int]bool)
+	for rows.Next() {
+		var userID, challengeID int
+		if err := rows.Scan(&userID, &challengeID); err != nil {
+			return nil, err
+		}
+		if !solved[challengeID] {
+			solved[challengeID] = true
+			points[userID] += values[challengeID]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(`
+		SELECT a.user_id, a.points FROM awards a JOIN users u ON a.user_id = u.id WHERE u.team_id = ? AND a.timestamp < ?
+		UNION ALL
+		SELECT hu.user_id, -h.cost FROM hint_unlocks hu JOIN hints h ON hu.hint_id = h.id JOIN users u ON hu.user_id = u.id
+		WHERE COALESCE(hu.team_id, u.team_id) = ? AND hu.timestamp < ? AND `+chargedUnlock+`
+	`, teamID, cutoff, teamID, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID, change int
+		if err := rows.Scan(&userID, &change); err != nil {
+			return nil, err
+		}
+		points[userID] += change
+	}
+	return points, rows.Err()
+}
+
 // Returns the number of members in a team
 func CountTeamMembers(teamID int) (int, error) {
 	var count int
@@ -127,9 +188,20 @@ func CountTeamMembers(teamID int) (int, error) {
 	return count, nil
 }
 
+// SetTeamBanned bans or unbans a whole team
+func SetTeamBanned(teamID int, banned bool) error {
+	_, err := db.Exec("UPDATE teams SET banned = ? WHERE id = ?", banned, teamID)
+	if err == nil {
+		invalidateScores()
+		events.Publish(events.ScoresChanged{})
+	}
+	return err
+}
+
 // Deletes a team by ID
 func DeleteTeam(teamID int) error {
 	_, err := db.Exec("DELETE FROM teams WHERE id = ?", teamID)
+	invalidateScores()
 	return err
 }
 
@@ -161,5 +233,6 @@ func GenerateTestTeams(n int) error {
 			db.Exec("UPDATE users SET team_id = ? WHERE id = ?", team.ID, user.ID)
 		}
 	}
+	invalidateScores()
 	return nil
 }
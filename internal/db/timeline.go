@@ -0,0 +1,125 @@
+package db
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+)
+
+// ScorePoint is a scoreboard entry's score right after it changed
+type ScorePoint struct {
+	Time  time.Time
+	Score int
+}
+
+// scoreChange is a solve, award or hint unlock that changed a scoreboard entry's score
+type scoreChange struct {
+	id     int // scoreboard ID, negative for solo users
+	time   time.Time
+	points int
+}
+
+// scoreboardID returns the ID a user's points count towards on the scoreboard
+func scoreboardID(userID int, teamID sql.NullInt64) int {
+	if teamID.Valid {
+		return int(teamID.Int64)
+	}
+	return -userID
+}
+
+// GetScoreTimelines returns how the score of every entry of GetScoreboard grew, by scoreboard ID
+func GetScoreTimelines() (map[int][]ScorePoint, error) {
+	return scoreTimelines(scoreboardCutoff())
+}
+
+// GetLiveScoreTimelines is GetScoreTimelines ignoring the freeze
+func GetLiveScoreTimelines() (map[int][]ScorePoint, error) {
+	return scoreTimelines(nil)
+}
+
+// scoreTimelines replays what happened before until. Solves count at the challenge's
+// current value as on the scoreboard, so each timeline ends at the entry's score.
+func scoreTimelines(until *time.Time) (map[int][]ScorePoint, error) {
+	values, err := getChallengeValues(until)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := sqlTime(until)
+	rows, err := db.Query(`
+		SELECT s.user_id, u.team_id, s.challenge_id, s.timestamp
+		FROM submissions s
+		JOIN users u ON s.user_id = u.id
+		WHERE s.correct AND s.timestamp < ?
+		ORDER BY s.timestamp, s.id
+	`, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []scoreChange
+	solved := make(map[[2]int]bool) // scoreboard ID and challenge ID
+	for rows.Next() {
+		var userID, challengeID int
+		var teamID sql.NullInt64
+		var timestamp time.Time
+		if err := rows.Scan(&userID, &teamID, &challengeID, &timestamp); err != nil {
+			return nil, err
+		}
+		id := scoreboardID(userID, teamID)
+		if solved[[2]int{id, challengeID}] {
+			continue
+		}
+		solved[[2]int{id, challengeID}] = true
+		changes = append(changes, scoreChange{id, timestamp, values[challengeID]})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	awards, err := getAwardChanges(cutoff)
+	if err != nil {
+		return nil, err
+	}
+	hints, err := getHintChanges(cutoff)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(append(changes, awards...), hints...)
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].time.Before(changes[j].time) })
+
+	timelines := make(map[int][]ScorePoint)
+	for _, c := range changes {
+		score := c.points
+		if points := timelines[c.id]; len(points) > 0 {
+			score += points[len(points)-1].Score
+		}
+		timelines[c.id] = append(timelines[c.id], ScorePoint{c.time, score})
+	}
+	return timelines, nil
+}
+
+// queryScoreChanges runs a query selecting team ID, user ID, points and time
+func queryScoreChanges(query string, args ...any) ([]scoreChange, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []scoreChange
+	for rows.Next() {
+		var teamID, userID sql.NullInt64
+		var c scoreChange
+		if err := rows.Scan(&teamID, &userID, &c.points, &c.time); err != nil {
+			return nil, err
+		}
+		if !teamID.Valid && !userID.Valid {
+			continue
+		}
+		c.id = scoreboardID(int(userID.Int64), teamID)
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
@@ -1,16 +1,44 @@
 package db
 
+import (
+	"slices"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"ctfsh/internal/events"
+)
+
 type User struct {
 	ID       int
 	Username string
 	SSHKey   string
 	TeamID   *int
+	Banned   bool // the user or their team is banned
+}
+
+// Player is a user as listed in the admin console
+type Player struct {
+	ID         int
+	Username   string
+	TeamName   string
+	Banned     bool
+	TeamBanned bool
+}
+
+// IsAdmin reports whether the user's key is one of the configured admin keys
+func (u *User) IsAdmin() bool {
+	key, err := gossh.ParsePublicKey([]byte(u.SSHKey))
+	if err != nil {
+		return false
+	}
+	return slices.Contains(cfg.Admins, gossh.FingerprintSHA256(key))
 }
 
 func GetUserBySSHKey(sshKey string) (*User, error) {
 	user := &User{}
-	err := db.QueryRow("SELECT id, username, ssh_key, team_id FROM users WHERE ssh_key = ?", sshKey).
-		Scan(&user.ID, &user.Username, &user.SSHKey, &user.TeamID)
+	err := db.QueryRow(`SELECT u.id, u.username, u.ssh_key, u.team_id, u.banned OR COALESCE(t.banned, FALSE)
+		FROM users u LEFT JOIN teams t ON u.team_id = t.id WHERE u.ssh_key = ?`, []byte(sshKey)).
+		Scan(&user.ID, &user.Username, &user.SSHKey, &user.TeamID, &user.Banned)
 	return user, err
 }
 
@@ -21,32 +49,46 @@ func GetUserByUsername(username string) (*User, error) {
 	return user, err
 }
 
+// CreateUser registers a player by the wire format of their public key, which is stored as bytes
 func CreateUser(username, sshKey string) (*User, error) {
-	result, err := db.Exec("INSERT INTO users (username, ssh_key) VALUES (?, ?)", username, sshKey)
-	if err != nil {
-		return nil, err
-	}
-	id, err := result.LastInsertId()
+	id, err := db.Insert("INSERT INTO users (username, ssh_key) VALUES (?, ?)", username, []byte(sshKey))
 	if err != nil {
 		return nil, err
 	}
+	invalidateScores()
 	return &User{ID: int(id), Username: username, SSHKey: sshKey}, nil
 }
 
-func GetChallengesSolvedByUser(userID int) (map[int]bool, error) {
-	rows, err := db.Query("SELECT DISTINCT challenge_id FROM submissions WHERE user_id = ? AND correct = 1", userID)
+// GetPlayers returns every user with their team, for the admin console
+func GetPlayers() ([]Player, error) {
+	rows, err := db.Query(`
+		SELECT u.id, u.username, COALESCE(t.name, ''), u.banned, COALESCE(t.banned, FALSE)
+		FROM users u
+		LEFT JOIN teams t ON u.team_id = t.id
+		ORDER BY u.username
+	`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	solved := make(map[int]bool)
+	var players []Player
 	for rows.Next() {
-		var challengeID int
-		if err := rows.Scan(&challengeID); err != nil {
+		var p Player
+		if err := rows.Scan(&p.ID, &p.Username, &p.TeamName, &p.Banned, &p.TeamBanned); err != nil {
 			return nil, err
 		}
-		solved[challengeID] = true
+		players = append(players, p)
+	}
+	return players, rows.Err()
+}
+
+// SetUserBanned bans or unbans a user. Banned users can't connect or submit flags and are left off the scoreboard.
+func SetUserBanned(userID int, banned bool) error {
+	_, err := db.Exec("UPDATE users SET banned = ? WHERE id = ?", banned, userID)
+	if err == nil {
+		invalidateScores()
+		events.Publish(events.ScoresChanged{})
 	}
-	return solved, nil
+	return err
 }
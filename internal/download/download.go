@@ -10,6 +10,8 @@ import (
 	"github.com/charmbracelet/ssh"
 	"github.com/charmbracelet/wish"
 	"github.com/pkg/sftp"
+
+	"ctfsh/internal/config"
 )
 
 type sftpHandler struct {
@@ -56,8 +58,13 @@ func (s *sftpHandler) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
 	}
 }
 
-func SftpSubsystem(root string) ssh.SubsystemHandler {
+func SftpSubsystem(cfg *config.Config) ssh.SubsystemHandler {
 	return func(s ssh.Session) {
+		root, err := sessionView(cfg, s)
+		if err != nil {
+			wish.Fatalln(s, "sftp:", err)
+			return
+		}
 		fs := &sftpHandler{root}
 		srv := sftp.NewRequestServer(s, sftp.Handlers{
 			FileList: fs,
@@ -11,25 +11,55 @@ import (
 	"ctfsh/internal/db"
 )
 
-func PrepareChallengeFS(challenges map[string]db.Challenge) error {
-	os.RemoveAll(config.DownloadRoot)
+// PrepareChallengeFS copies every challenge's downloads into the shared copy. Nothing
+// there is served directly, players only see the challenges released to them through View.
+func PrepareChallengeFS(cfg *config.Config, challenges map[string]db.Challenge) error {
+	os.RemoveAll(cfg.DownloadRoot)
+	resetViews()
 	for _, ch := range challenges {
-		srcDir := config.ChallengeDir + "/" + strings.ToLower(ch.Name)
-		tgtDir := filepath.Join(config.DownloadRoot, ch.Name)
-		if err := os.MkdirAll(tgtDir, 0755); err != nil {
+		if err := prepareChallenge(cfg, ch); err != nil {
 			return err
 		}
-		for _, f := range ch.Downloads {
-			srcPath := filepath.Join(srcDir, f)
-			dstPath := filepath.Join(tgtDir, f)
-			if err := copyFileOrDir(srcPath, dstPath); err != nil {
-				log.Printf("Failed to copy %s for challenge %s: %v", f, ch.Name, err)
+	}
+	return nil
+}
+
+// RefreshChallengeFS copies the downloads of the named challenges again, dropping those
+// that are gone, and makes every team's view pick up the change
+func RefreshChallengeFS(cfg *config.Config, names []string) error {
+	challenges := db.GetChallenges()
+	viewsMu.Lock()
+	defer viewsMu.Unlock()
+	views = make(map[string]*view)
+	for _, name := range names {
+		if err := os.RemoveAll(filepath.Join(challengesRoot(cfg), name)); err != nil {
+			return err
+		}
+		if ch, ok := challenges[name]; ok {
+			if err := prepareChallenge(cfg, ch); err != nil {
+				return err
 			}
 		}
 	}
 	return nil
 }
 
+func prepareChallenge(cfg *config.Config, ch db.Challenge) error {
+	srcDir := cfg.ChallengeDir + "/" + strings.ToLower(ch.Name)
+	tgtDir := filepath.Join(challengesRoot(cfg), ch.Name)
+	if err := os.MkdirAll(tgtDir, 0755); err != nil {
+		return err
+	}
+	for _, f := range ch.Downloads {
+		srcPath := filepath.Join(srcDir, f)
+		dstPath := filepath.Join(tgtDir, f)
+		if err := copyFileOrDir(srcPath, dstPath); err != nil {
+			log.Printf("Failed to copy %s for challenge %s: %v", f, ch.Name, err)
+		}
+	}
+	return nil
+}
+
 func copyFileOrDir(src, dst string) error {
 	info, err := os.Stat(src)
 	if err != nil {
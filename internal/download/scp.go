@@ -0,0 +1,68 @@
+package download
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish/scp"
+
+	"ctfsh/internal/config"
+)
+
+// scpHandler serves scp downloads from the view of whoever is connected
+type scpHandler struct {
+	cfg *config.Config
+}
+
+var _ scp.CopyToClientHandler = &scpHandler{}
+
+func ScpHandler(cfg *config.Config) scp.CopyToClientHandler {
+	return &scpHandler{cfg}
+}
+
+func (h *scpHandler) handler(s ssh.Session, path string) (scp.Handler, error) {
+	if slices.Contains(strings.Split(filepath.ToSlash(path), "/"), "..") {
+		return nil, errors.New("invalid path")
+	}
+	root, err := sessionView(h.cfg, s)
+	if err != nil {
+		return nil, err
+	}
+	return scp.NewFileSystemHandler(root), nil
+}
+
+func (h *scpHandler) Glob(s ssh.Session, pattern string) ([]string, error) {
+	fsh, err := h.handler(s, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return fsh.Glob(s, pattern)
+}
+
+func (h *scpHandler) WalkDir(s ssh.Session, path string, fn fs.WalkDirFunc) error {
+	fsh, err := h.handler(s, path)
+	if err != nil {
+		return err
+	}
+	return fsh.WalkDir(s, path, fn)
+}
+
+func (h *scpHandler) NewDirEntry(s ssh.Session, path string) (*scp.DirEntry, error) {
+	fsh, err := h.handler(s, path)
+	if err != nil {
+		return nil, err
+	}
+	return fsh.NewDirEntry(s, path)
+}
+
+func (h *scpHandler) NewFileEntry(s ssh.Session, path string) (*scp.FileEntry, func() error, error) {
+	fsh, err := h.handler(s, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fsh.NewFileEntry(s, path)
+}
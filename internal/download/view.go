@@ -0,0 +1,131 @@
+package download
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/charmbracelet/ssh"
+
+	"ctfsh/internal/config"
+	"ctfsh/internal/db"
+)
+
+var (
+	errNotRegistered = errors.New("downloads are only for registered players, connect with ssh to register first")
+	errBanned        = errors.New("you have been banned from this CTF")
+)
+
+// view is the download directory of one team, holding the released challenges it has unlocked
+type view struct {
+	root  string
+	chals map[string]bool
+}
+
+var (
+	viewsMu sync.Mutex
+	views   = make(map[string]*view) // flag owner -> view
+)
+
+// challengesRoot holds the downloads as they are in the challenge directories
+func challengesRoot(cfg *config.Config) string {
+	return filepath.Join(cfg.DownloadRoot, "challenges")
+}
+
+func resetViews() {
+	viewsMu.Lock()
+	views = make(map[string]*view)
+	viewsMu.Unlock()
+}
+
+// View returns the directory with the downloads as the user's team sees them, bringing it up to date
+// with the challenges that are released and the team has unlocked. Files are hard linked from the shared copy, except those
+// containing a per-team flag, which get the team's flag.
+func View(cfg *config.Config, user *db.User) (string, error) {
+	progress, err := db.GetProgress(user)
+	if err != nil {
+		return "", err
+	}
+	owner := db.FlagOwner(user)
+	viewsMu.Lock()
+	defer viewsMu.Unlock()
+
+	v, ok := views[owner]
+	if !ok {
+		v = &view{root: filepath.Join(cfg.DownloadRoot, "views", owner), chals: make(map[string]bool)}
+		if err := os.RemoveAll(v.root); err != nil {
+			return "", err
+		}
+		if err := os.MkdirAll(v.root, 0755); err != nil {
+			return "", err
+		}
+		views[owner] = v
+	}
+
+	for _, ch := range db.GetChallenges() {
+		dst := filepath.Join(v.root, ch.Name)
+		unlocked := ch.Released() && progress.Unlocked(ch)
+		if unlocked && !v.chals[ch.Name] {
+			src := filepath.Join(challengesRoot(cfg), ch.Name)
+			if err := linkTree(src, dst, ch, user); err != nil {
+				return "", err
+			}
+			v.chals[ch.Name] = true
+		} else if !unlocked && v.chals[ch.Name] {
+			if err := os.RemoveAll(dst); err != nil {
+				return "", err
+			}
+			delete(v.chals, ch.Name)
+		}
+	}
+	return v.root, nil
+}
+
+func linkTree(src, dst string, ch db.Challenge, user *db.User) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		if ch.PerTeam() {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if bytes.Contains(data, []byte(ch.Flag)) {
+				data = bytes.ReplaceAll(data, []byte(ch.Flag), []byte(db.FlagFor(ch, user)))
+				return os.WriteFile(target, data, 0644)
+			}
+		}
+		if err := os.Link(path, target); err != nil {
+			return copyFile(path, target)
+		}
+		return nil
+	})
+}
+
+// sessionView returns the download view of the player behind an ssh session
+func sessionView(cfg *config.Config, s ssh.Session) (string, error) {
+	if s.PublicKey() == nil {
+		return "", errNotRegistered
+	}
+	user, err := db.GetUserBySSHKey(string(s.PublicKey().Marshal()))
+	if err != nil {
+		return "", errNotRegistered
+	}
+	if user.Banned {
+		return "", errBanned
+	}
+	return View(cfg, user)
+}
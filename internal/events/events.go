@@ -0,0 +1,53 @@
+// Package events pushes messages into every connected TUI session
+package events
+
+import (
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var (
+	mu       sync.Mutex
+	programs = make(map[*tea.Program]bool)
+)
+
+// Subscribe makes p receive every published message until unsubscribe is called
+func Subscribe(p *tea.Program) (unsubscribe func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	programs[p] = true
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		delete(programs, p)
+	}
+}
+
+// Publish sends msg to every subscribed program without waiting for them to handle it
+func Publish(msg tea.Msg) {
+	mu.Lock()
+	defer mu.Unlock()
+	for p := range programs {
+		go p.Send(msg)
+	}
+}
+
+// Announcement is published when an admin posts an announcement
+type Announcement struct {
+	Author    string
+	Message   string
+	Timestamp time.Time
+}
+
+// Solve is published when a player submits a correct flag
+type Solve struct {
+	UserID    int
+	Username  string
+	TeamID    *int
+	Challenge string
+}
+
+// ScoresChanged is published when scores change other than by a solve, e.g. an admin adjustment
+type ScoresChanged struct{}
@@ -0,0 +1,54 @@
+package instance
+
+import (
+	"fmt"
+
+	"ctfsh/internal/config"
+	"ctfsh/internal/db"
+)
+
+// InstanceBackend is implemented by everything that can run challenge instances
+type InstanceBackend interface {
+	// BuildImage prepares whatever is needed to start instances of chal
+	BuildImage(chal db.Challenge) error
+	// RemoveImage removes what BuildImage prepared for chal, so that the next build starts over
+	RemoveImage(chal db.Challenge) error
+	// Start launches a new instance of chal called name, with env set for the challenge's processes
+	Start(chal db.Challenge, name string, env map[string]string) error
+	// Stop stops and removes the named instance
+	Stop(name string) error
+	// Address returns the host:port to dial for a challenge port of the named instance
+	Address(name string, port int) (string, error)
+	// Exec runs a shell command inside the named instance
+	Exec(name string, command string) error
+	// List returns the names of all running instances
+	List() ([]string, error)
+}
+
+var backend InstanceBackend
+
+func newBackend(c *config.Config) (InstanceBackend, error) {
+	switch c.InstanceBackend {
+	case "incus":
+		return &incusBackend{}, nil
+	case "docker":
+		return newDockerBackend(c.DockerSocket), nil
+	case "local":
+		return newLocalBackend(), nil
+	default:
+		return nil, fmt.Errorf("unknown instance backend %q", c.InstanceBackend)
+	}
+}
+
+// CreateChallengeImage builds the image for chal on the configured backend
+func CreateChallengeImage(chal db.Challenge) error {
+	return backend.BuildImage(chal)
+}
+
+// RebuildChallengeImage builds the image for chal again, replacing one built from older files
+func RebuildChallengeImage(chal db.Challenge) error {
+	if err := backend.RemoveImage(chal); err != nil {
+		return fmt.Errorf("removing old image: %w", err)
+	}
+	return backend.BuildImage(chal)
+}
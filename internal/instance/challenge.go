@@ -1,34 +1,56 @@
 package instance
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/charmbracelet/log"
 	"github.com/lxc/incus/shared/api"
 
-	"ctfsh/internal/config"
-	"ctfsh/internal/util"
+	"ctfsh/internal/db"
 )
 
-func CreateChallengeImage(name string, challengePath string) {
-	c := getIncusConnection()
+func (b *incusBackend) BuildImage(chal db.Challenge) error {
+	name := chal.Name
+	lock, _ := b.builds.LoadOrStore(name, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	c, err := getIncusConnection()
+	if err != nil {
+		return err
+	}
+	challengePath := getChallengePath(name)
+	if challengePath == "" {
+		return fmt.Errorf("challenge directory for %s not found", name)
+	}
 	builderName := name + "-builder"
 
 	// Check if image already exists
 	images, err := c.GetImages()
-	util.Must(err)
+	if err != nil {
+		return fmt.Errorf("listing images: %w", err)
+	}
 	for _, img := range images {
 		for _, alias := range img.Aliases {
 			if alias.Name == "ctfsh/"+name {
-				return
+				return nil
 			}
 		}
 	}
 
-	ensureNetworkExists("chals")
+	if err := ensureNetworkExists("chals"); err != nil {
+		return err
+	}
+
+	// Clear out a builder left behind by an earlier failed build
+	if err := deleteInstanceIfExists(builderName); err != nil {
+		return err
+	}
 
-	op, err := c.CreateInstance(api.InstancesPost{
+	err = wait(c.CreateInstance(api.InstancesPost{
 		Name: builderName,
 		InstancePut: api.InstancePut{
 			Architecture: "x86_64",
@@ -42,7 +64,7 @@ func CreateChallengeImage(name string, challengePath string) {
 					"path":   "/mnt/chal",
 				},
 				"eth0": {
-					"type":   "nic",
+					"type":    "nic",
 					"network": "chals",
 				},
 			},
@@ -53,32 +75,46 @@ func CreateChallengeImage(name string, challengePath string) {
 			Server:   "https://images.linuxcontainers.org",
 			Protocol: "simplestreams",
 		},
-	})
-	util.Must(err)
-	util.Must(op.Wait())
+	}))
+	if err != nil {
+		return fmt.Errorf("creating builder for %s: %w", name, err)
+	}
+	defer func() {
+		if err := deleteInstanceIfExists(builderName); err != nil {
+			log.Error("Failed to delete builder", "name", builderName, "error", err)
+		}
+	}()
 
-	op, err = c.UpdateInstanceState(builderName, api.InstanceStatePut{
+	err = wait(c.UpdateInstanceState(builderName, api.InstanceStatePut{
 		Action:  "start",
 		Timeout: -1,
-	}, "")
-	util.Must(err)
-	util.Must(op.Wait())
-
-	runCmdInContainer(c, builderName, `while ! ip addr show eth0 | grep -q "inet "; do echo "Waiting for IP..."; sleep 1; done`)
-	runCmdInContainer(c, builderName, `apk add docker docker-compose`)
-	runCmdInContainer(c, builderName, `rc-update add docker default`)
-	runCmdInContainer(c, builderName, `service docker start`)
-	runCmdInContainer(c, builderName, `mkdir -p /chal && cp -r /mnt/chal/* /chal/`)
-	runCmdInContainer(c, builderName, `cd /chal && docker compose build && docker compose create`)
-
-	op, err = c.UpdateInstanceState(builderName, api.InstanceStatePut{
+	}, ""))
+	if err != nil {
+		return fmt.Errorf("starting builder for %s: %w", name, err)
+	}
+
+	for _, cmd := range []string{
+		`while ! ip addr show eth0 | grep -q "inet "; do echo "Waiting for IP..."; sleep 1; done`,
+		`apk add docker docker-compose`,
+		`rc-update add docker default`,
+		`service docker start`,
+		`mkdir -p /chal && cp -r /mnt/chal/* /chal/`,
+		`cd /chal && docker compose build && docker compose create`,
+	} {
+		if err := runCmdInContainer(c, builderName, cmd); err != nil {
+			return err
+		}
+	}
+
+	err = wait(c.UpdateInstanceState(builderName, api.InstanceStatePut{
 		Action:  "stop",
 		Timeout: -1,
-	}, "")
-	util.Must(err)
-	util.Must(op.Wait())
+	}, ""))
+	if err != nil {
+		return fmt.Errorf("stopping builder for %s: %w", name, err)
+	}
 
-	op, err = c.CreateImage(api.ImagesPost{
+	err = wait(c.CreateImage(api.ImagesPost{
 		Source: &api.ImagesPostSource{
 			Type: "container",
 			Name: builderName,
@@ -87,32 +123,62 @@ func CreateChallengeImage(name string, challengePath string) {
 			Name:        "ctfsh/" + name,
 			Description: "CTFsh container for " + name,
 		}},
-	}, nil)
-	util.Must(err)
-	util.Must(op.Wait())
+	}, nil))
+	if err != nil {
+		return fmt.Errorf("creating image for %s: %w", name, err)
+	}
+	return nil
+}
 
-	util.Must(err)
-	util.Must(op.Wait())
+func (b *incusBackend) RemoveImage(chal db.Challenge) error {
+	lock, _ := b.builds.LoadOrStore(chal.Name, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
 
-	op, err = c.DeleteInstance(builderName)
-	util.Must(err)
+	c, err := getIncusConnection()
+	if err != nil {
+		return err
+	}
+	alias, _, err := c.GetImageAlias("ctfsh/" + chal.Name)
+	if err != nil {
+		return nil // never built
+	}
+	if err := wait(c.DeleteImage(alias.Target)); err != nil {
+		return fmt.Errorf("deleting image for %s: %w", chal.Name, err)
+	}
+	return nil
 }
 
-func StartChallenge(image string, name string) {
-	c := getIncusConnection()
-	CreateChallengeImage(image, getChallengePath(image))
-	deleteInstanceIfExists(name)
+func (b *incusBackend) Start(chal db.Challenge, name string, env map[string]string) error {
+	c, err := getIncusConnection()
+	if err != nil {
+		return err
+	}
+	image := chal.Name
+	if err := b.BuildImage(chal); err != nil {
+		return err
+	}
+	if err := deleteInstanceIfExists(name); err != nil {
+		return err
+	}
 
-	op, err := c.CreateInstance(api.InstancesPost{
+	config := map[string]string{
+		"security.nesting": "true",
+		instanceConfigKey:  image,
+	}
+	// Picked up by docker compose, so the compose file can pass them on with ${VAR}
+	for k, v := range env {
+		config["environment."+k] = v
+	}
+
+	err = wait(c.CreateInstance(api.InstancesPost{
 		Name: name,
 		InstancePut: api.InstancePut{
 			Architecture: "x86_64",
-			Config: map[string]string{
-				"security.nesting": "true",
-			},
+			Config:       config,
 			Devices: map[string]map[string]string{
 				"eth0": {
-					"type":   "nic",
+					"type":    "nic",
 					"network": "chals",
 				},
 			},
@@ -121,22 +187,24 @@ func StartChallenge(image string, name string) {
 			Type:  "image",
 			Alias: "ctfsh/" + image,
 		},
-	})
-	util.Must(err)
-	util.Must(op.Wait())
+	}))
+	if err != nil {
+		return fmt.Errorf("creating instance %s: %w", name, err)
+	}
 
-	startOp, err := c.UpdateInstanceState(name, api.InstanceStatePut{
+	err = wait(c.UpdateInstanceState(name, api.InstanceStatePut{
 		Action:  "start",
 		Timeout: -1,
-	}, "")
-	util.Must(err)
-	util.Must(startOp.Wait())
+	}, ""))
+	if err != nil {
+		return fmt.Errorf("starting instance %s: %w", name, err)
+	}
 
-	runCmdInContainer(c, name, `cd /chal && until docker info >/dev/null 2>&1; do sleep 1; done; docker compose up -d`)
+	return runCmdInContainer(c, name, `cd /chal && until docker info >/dev/null 2>&1; do sleep 1; done; docker compose up -d`)
 }
 
 func getChallengePath(name string) string {
-	p, err := filepath.Abs(config.ChallengeDir + "/" + name)
+	p, err := filepath.Abs(cfg.ChallengeDir + "/" + name)
 	if err != nil {
 		log.Error("Failed to get absolute path for challenge", "name", name, "error", err)
 		return ""
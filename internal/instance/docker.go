@@ -0,0 +1,507 @@
+package instance
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"gopkg.in/yaml.v3"
+
+	"ctfsh/internal/db"
+)
+
+const (
+	dockerNetwork     = "ctfsh-chals"
+	dockerInstanceTag = "ctfsh.challenge"
+)
+
+// dockerBackend runs each instance as a single container on the Docker Engine API.
+// Challenges using docker compose are translated from the first service in their compose file.
+type dockerBackend struct {
+	client *http.Client
+
+	mu    sync.Mutex
+	ports map[string]map[int]int // instance name -> challenge port -> container port
+}
+
+// composeFile is the subset of a docker compose file the docker backend understands
+type composeFile struct {
+	Services map[string]struct {
+		Build       yaml.Node `yaml:"build"`
+		Ports       []string  `yaml:"ports"`
+		Environment yaml.Node `yaml:"environment"`
+	} `yaml:"services"`
+}
+
+// dockerService describes how to build and run a challenge container
+type dockerService struct {
+	context    string
+	dockerfile string
+	ports      map[int]int
+	env        []string
+}
+
+func newDockerBackend(socket string) *dockerBackend {
+	return &dockerBackend{
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+		ports: make(map[string]map[int]int),
+	}
+}
+
+// request calls the Docker Engine API and decodes a JSON response into out if it is not nil
+func (b *dockerBackend) request(method, path string, body io.Reader, contentType string, out any) error {
+	req, err := http.NewRequest(method, "http://docker"+path, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		return &dockerError{status: resp.StatusCode, message: apiErr.Message}
+	}
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (b *dockerBackend) requestJSON(method, path string, in any, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return b.request(method, path, bytes.NewReader(body), "application/json", out)
+}
+
+type dockerError struct {
+	status  int
+	message string
+}
+
+func (e *dockerError) Error() string {
+	return fmt.Sprintf("docker: %s (status %d)", e.message, e.status)
+}
+
+func isNotFound(err error) bool {
+	var dockerErr *dockerError
+	return errors.As(err, &dockerErr) && dockerErr.status == http.StatusNotFound
+}
+
+func dockerImage(chal db.Challenge) string {
+	return "ctfsh/" + chal.Name
+}
+
+// loadService reads the challenge's compose file, falling back to a plain Dockerfile in its build directory
+func loadService(chal db.Challenge) (*dockerService, error) {
+	dir := getChallengePath(chal.Name)
+	if dir == "" {
+		return nil, fmt.Errorf("challenge directory for %s not found", chal.Name)
+	}
+	dir = filepath.Join(dir, chal.BuildDir)
+
+	svc := &dockerService{context: dir, dockerfile: "Dockerfile", ports: make(map[int]int)}
+	for _, port := range chal.Ports {
+		svc.ports[port] = port
+	}
+
+	var data []byte
+	for _, name := range []string{"docker-compose.yaml", "docker-compose.yml", "compose.yaml", "compose.yml"} {
+		d, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			data = d
+			break
+		}
+	}
+	if data == nil {
+		return svc, nil
+	}
+
+	var compose composeFile
+	if err := yaml.Unmarshal(data, &compose); err != nil {
+		return nil, fmt.Errorf("parsing compose file: %w", err)
+	}
+	if len(compose.Services) == 0 {
+		return svc, nil
+	}
+	if len(compose.Services) > 1 {
+		log.Warn("Docker backend only runs the first compose service", "challenge", chal.Name)
+	}
+	names := make([]string, 0, len(compose.Services))
+	for name := range compose.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	service := compose.Services[names[0]]
+
+	// build is either a context path or a mapping with context and dockerfile
+	switch service.Build.Kind {
+	case yaml.ScalarNode:
+		svc.context = filepath.Join(dir, service.Build.Value)
+	case yaml.MappingNode:
+		var build struct {
+			Context    string `yaml:"context"`
+			Dockerfile string `yaml:"dockerfile"`
+		}
+		if err := service.Build.Decode(&build); err != nil {
+			return nil, err
+		}
+		svc.context = filepath.Join(dir, build.Context)
+		if build.Dockerfile != "" {
+			svc.dockerfile = build.Dockerfile
+		}
+	}
+
+	// ports are "[ip:]published:target", where published is what players forward to
+	for _, mapping := range service.Ports {
+		parts := strings.Split(strings.TrimSuffix(mapping, "/tcp"), ":")
+		target, err := strconv.Atoi(parts[len(parts)-1])
+		if err != nil {
+			return nil, fmt.Errorf("unsupported port mapping %q", mapping)
+		}
+		published := target
+		if len(parts) > 1 {
+			if published, err = strconv.Atoi(parts[len(parts)-2]); err != nil {
+				return nil, fmt.Errorf("unsupported port mapping %q", mapping)
+			}
+		}
+		svc.ports[published] = target
+	}
+
+	// environment is either a list of KEY=VALUE or a mapping
+	switch service.Environment.Kind {
+	case yaml.SequenceNode:
+		if err := service.Environment.Decode(&svc.env); err != nil {
+			return nil, err
+		}
+	case yaml.MappingNode:
+		var env map[string]string
+		if err := service.Environment.Decode(&env); err != nil {
+			return nil, err
+		}
+		for k, v := range env {
+			svc.env = append(svc.env, k+"="+v)
+		}
+	}
+	return svc, nil
+}
+
+// interpolate expands $VAR, ${VAR} and ${VAR:-default} in a compose value from env
+func interpolate(value string, env map[string]string) string {
+	return os.Expand(value, func(name string) string {
+		name, def, hasDefault := strings.Cut(name, ":-")
+		if v, ok := env[name]; ok && (v != "" || !hasDefault) {
+			return v
+		}
+		return def
+	})
+}
+
+func (b *dockerBackend) BuildImage(chal db.Challenge) error {
+	err := b.request(http.MethodGet, "/images/"+dockerImage(chal)+"/json", nil, "", nil)
+	if err == nil {
+		return nil
+	}
+	if !isNotFound(err) {
+		return err
+	}
+
+	svc, err := loadService(chal)
+	if err != nil {
+		return err
+	}
+
+	// Stream the build context as a tar archive
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(tarDir(svc.context, pw))
+	}()
+
+	query := url.Values{"t": {dockerImage(chal)}, "dockerfile": {svc.dockerfile}, "rm": {"1"}}
+	req, err := http.NewRequest(http.MethodPost, "http://docker/build?"+query.Encode(), pr)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-tar")
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(resp.Body)
+		return &dockerError{status: resp.StatusCode, message: strings.TrimSpace(string(msg))}
+	}
+
+	// The build result is a stream of JSON messages, any of which may report a failure
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Stream string `json:"stream"`
+			Error  string `json:"error"`
+		}
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("building %s: %s", chal.Name, msg.Error)
+		}
+		os.Stdout.WriteString(msg.Stream)
+	}
+}
+
+func (b *dockerBackend) RemoveImage(chal db.Challenge) error {
+	err := b.request(http.MethodDelete, "/images/"+dockerImage(chal)+"?force=1", nil, "", nil)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func tarDir(dir string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func (b *dockerBackend) ensureNetwork() error {
+	err := b.request(http.MethodGet, "/networks/"+dockerNetwork, nil, "", nil)
+	if !isNotFound(err) {
+		return err
+	}
+	return b.requestJSON(http.MethodPost, "/networks/create", map[string]any{
+		"Name":   dockerNetwork,
+		"Driver": "bridge",
+	}, nil)
+}
+
+func (b *dockerBackend) Start(chal db.Challenge, name string, env map[string]string) error {
+	if err := b.BuildImage(chal); err != nil {
+		return err
+	}
+	if err := b.ensureNetwork(); err != nil {
+		return err
+	}
+	svc, err := loadService(chal)
+	if err != nil {
+		return err
+	}
+
+	// Interpolate the compose environment like docker compose would, then pass env on as is
+	containerEnv := make([]string, 0, len(svc.env)+len(env))
+	for _, kv := range svc.env {
+		containerEnv = append(containerEnv, interpolate(kv, env))
+	}
+	for k, v := range env {
+		containerEnv = append(containerEnv, k+"="+v)
+	}
+
+	err = b.requestJSON(http.MethodPost, "/containers/create?name="+url.QueryEscape(name), map[string]any{
+		"Image":  dockerImage(chal),
+		"Env":    containerEnv,
+		"Labels": map[string]string{dockerInstanceTag: chal.Name},
+		"HostConfig": map[string]any{
+			"NetworkMode": dockerNetwork,
+		},
+	}, nil)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.ports[name] = svc.ports
+	b.mu.Unlock()
+
+	return b.request(http.MethodPost, "/containers/"+name+"/start", nil, "", nil)
+}
+
+func (b *dockerBackend) Stop(name string) error {
+	b.mu.Lock()
+	delete(b.ports, name)
+	b.mu.Unlock()
+
+	err := b.request(http.MethodDelete, "/containers/"+name+"?force=1", nil, "", nil)
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Info("Challenge stopped and container deleted", "name", name)
+	return nil
+}
+
+func (b *dockerBackend) Address(name string, port int) (string, error) {
+	var inspect struct {
+		NetworkSettings struct {
+			Networks map[string]struct {
+				IPAddress string `json:"IPAddress"`
+			} `json:"Networks"`
+		} `json:"NetworkSettings"`
+	}
+	if err := b.request(http.MethodGet, "/containers/"+name+"/json", nil, "", &inspect); err != nil {
+		return "", err
+	}
+	ip := inspect.NetworkSettings.Networks[dockerNetwork].IPAddress
+	if ip == "" {
+		return "", fmt.Errorf("no address for instance %s", name)
+	}
+
+	b.mu.Lock()
+	target, ok := b.ports[name][port]
+	b.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("port %d is not exposed by instance %s", port, name)
+	}
+	return net.JoinHostPort(ip, strconv.Itoa(target)), nil
+}
+
+func (b *dockerBackend) Exec(name string, command string) error {
+	var created struct {
+		ID string `json:"Id"`
+	}
+	err := b.requestJSON(http.MethodPost, "/containers/"+name+"/exec", map[string]any{
+		"Cmd":          []string{"sh", "-c", command},
+		"AttachStdout": true,
+		"AttachStderr": true,
+	}, &created)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]any{"Detach": false, "Tty": false})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, "http://docker/exec/"+created.ID+"/start", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := demuxOutput(resp.Body); err != nil {
+		return err
+	}
+
+	var inspect struct {
+		ExitCode int `json:"ExitCode"`
+	}
+	if err := b.request(http.MethodGet, "/exec/"+created.ID+"/json", nil, "", &inspect); err != nil {
+		return err
+	}
+	if inspect.ExitCode != 0 {
+		return fmt.Errorf("command exited with status %d", inspect.ExitCode)
+	}
+	return nil
+}
+
+// demuxOutput copies docker's multiplexed stdout/stderr stream to the server's own output
+func demuxOutput(r io.Reader) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		out := os.Stdout
+		if header[0] == 2 {
+			out = os.Stderr
+		}
+		if _, err := io.CopyN(out, r, int64(binary.BigEndian.Uint32(header[4:]))); err != nil {
+			return err
+		}
+	}
+}
+
+func (b *dockerBackend) List() ([]string, error) {
+	filters, err := json.Marshal(map[string][]string{"label": {dockerInstanceTag}})
+	if err != nil {
+		return nil, err
+	}
+	var containers []struct {
+		Names []string `json:"Names"`
+	}
+	if err := b.request(http.MethodGet, "/containers/json?filters="+url.QueryEscape(string(filters)), nil, "", &containers); err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, c := range containers {
+		if len(c.Names) > 0 {
+			names = append(names, strings.TrimPrefix(c.Names[0], "/"))
+		}
+	}
+	return names, nil
+}
@@ -1,7 +1,6 @@
 package instance
 
 import (
-	"fmt"
 	"io"
 	"net"
 	"sync"
@@ -58,7 +57,12 @@ func DirectTCPChannelHandler(srv *ssh.Server, conn *gossh.ServerConn, newChan go
 	}
 
 	// Connect to the forwarded port
-	target, err := net.Dial("tcp", getContainerIp(containerName)+":"+fmt.Sprint(payload.DestPort))
+	addr, err := backend.Address(containerName, int(payload.DestPort))
+	if err != nil {
+		log.Error("Failed to get instance address", "error", err)
+		return
+	}
+	target, err := net.Dial("tcp", addr)
 	if err != nil {
 		log.Error("Failed to connect to forwarded port", "error", err)
 		return
@@ -2,49 +2,62 @@ package instance
 
 import (
 	"fmt"
+	"net"
 	"os"
+	"strconv"
+	"sync"
 
 	"github.com/charmbracelet/log"
 	"github.com/lxc/incus/client"
 	"github.com/lxc/incus/shared/api"
-
-	"ctfsh/internal/util"
 )
 
+// instanceConfigKey marks incus instances started for players, as opposed to image builders
+const instanceConfigKey = "user.ctfsh.challenge"
+
+// incusBackend runs each instance as an incus container with docker compose inside it
+type incusBackend struct {
+	builds sync.Map // image name -> *sync.Mutex, so concurrent requests don't race to build the same image
+}
+
 var incusConn incus.InstanceServer
 
-func getIncusConnection() incus.InstanceServer {
+func getIncusConnection() (incus.InstanceServer, error) {
 	if incusConn == nil {
 		conn, err := incus.ConnectIncusUnix("", nil)
-		util.Must(err)
+		if err != nil {
+			return nil, fmt.Errorf("connecting to incus: %w", err)
+		}
 		incusConn = conn
 	}
-	return incusConn
+	return incusConn, nil
 }
 
-func deleteInstanceIfExists(name string) {
-	c := getIncusConnection()
-	_, _, err := c.GetInstance(name)
-	if err == nil {
-		fmt.Printf("Instance %s already exists, deleting...\n", name)
-		inst, _, err := c.GetInstanceState(name)
-		util.Must(err)
-		if inst.StatusCode == api.Running {
-			op, err := c.UpdateInstanceState(name, api.InstanceStatePut{
-				Action:  "stop",
-				Timeout: 0,
-			}, "")
-			util.Must(err)
-			util.Must(op.Wait())
-		}
-		op, err := c.DeleteInstance(name)
-		util.Must(err)
-		util.Must(op.Wait())
-		fmt.Printf("Instance %s deleted successfully.\n", name)
+// wait finishes an incus operation, passing through the error from starting it
+func wait(op incus.Operation, err error) error {
+	if err != nil {
+		return err
 	}
+	return op.Wait()
 }
 
-func runCmdInContainer(c incus.InstanceServer, name, command string) {
+func deleteInstanceIfExists(name string) error {
+	c, err := getIncusConnection()
+	if err != nil {
+		return err
+	}
+	if _, _, err := c.GetInstance(name); err != nil {
+		return nil
+	}
+	fmt.Printf("Instance %s already exists, deleting...\n", name)
+	if err := stopContainer(name); err != nil {
+		return err
+	}
+	fmt.Printf("Instance %s deleted successfully.\n", name)
+	return nil
+}
+
+func runCmdInContainer(c incus.InstanceServer, name, command string) error {
 	execReq := api.InstanceExecPost{
 		Command:     []string{"sh", "-c", command},
 		WaitForWS:   true,
@@ -58,62 +71,77 @@ func runCmdInContainer(c incus.InstanceServer, name, command string) {
 	}
 
 	op, err := c.ExecInstance(name, execReq, &args)
-	util.Must(err)
-	util.Must(op.Wait())
+	if err != nil {
+		return fmt.Errorf("running %q in %s: %w", command, name, err)
+	}
+	if err := op.Wait(); err != nil {
+		return fmt.Errorf("running %q in %s: %w", command, name, err)
+	}
 	<-args.DataDone
+
+	if ret, ok := op.Get().Metadata["return"].(float64); ok && ret != 0 {
+		return fmt.Errorf("running %q in %s: exited with status %d", command, name, int(ret))
+	}
+	return nil
 }
 
-func getContainerIp(name string) string {
-	c := getIncusConnection()
+func getContainerIp(name string) (string, error) {
+	c, err := getIncusConnection()
+	if err != nil {
+		return "", err
+	}
 	inst, _, err := c.GetInstanceState(name)
-	if err != nil || len(inst.Network) == 0 {
-		log.Error("Failed to get instance state", "name", name, "error", err)
-		return ""
+	if err != nil {
+		return "", fmt.Errorf("getting state of %s: %w", name, err)
 	}
 
 	for _, net := range inst.Network {
 		for _, addr := range net.Addresses {
 			if addr.Family == "inet" {
 				if addr.Address[:2] == "10" {
-					return addr.Address
+					return addr.Address, nil
 				}
 			}
 		}
 	}
-
-	log.Error("No IPv4 address found for instance", "name", name)
-	return ""
+	return "", fmt.Errorf("no IPv4 address found for instance %s", name)
 }
 
-func stopContainer(name string) {
-	c := getIncusConnection()
+func stopContainer(name string) error {
+	c, err := getIncusConnection()
+	if err != nil {
+		return err
+	}
 	inst, _, err := c.GetInstanceState(name)
 	if err != nil {
-		log.Error("Failed to get instance state", "name", name, "error", err)
-		return
+		return fmt.Errorf("getting state of %s: %w", name, err)
 	}
 
 	if inst.StatusCode == api.Running {
-		op, err := c.UpdateInstanceState(name, api.InstanceStatePut{
+		err := wait(c.UpdateInstanceState(name, api.InstanceStatePut{
 			Action:  "stop",
 			Timeout: -1,
-		}, "")
-		util.Must(err)
-		util.Must(op.Wait())
+		}, ""))
+		if err != nil {
+			return fmt.Errorf("stopping %s: %w", name, err)
+		}
 	}
 
-	op, err := c.DeleteInstance(name)
-	util.Must(err)
-	util.Must(op.Wait())
+	if err := wait(c.DeleteInstance(name)); err != nil {
+		return fmt.Errorf("deleting %s: %w", name, err)
+	}
 	log.Info("Challenge stopped and instance deleted", "name", name)
+	return nil
 }
 
-func ensureNetworkExists(name string) {
-	c := getIncusConnection()
-	_, _, err := c.GetNetwork(name)
-	if err == nil {
+func ensureNetworkExists(name string) error {
+	c, err := getIncusConnection()
+	if err != nil {
+		return err
+	}
+	if _, _, err := c.GetNetwork(name); err == nil {
 		log.Info("Network already exists", "name", name)
-		return
+		return nil
 	}
 
 	log.Info("Creating network", "name", name)
@@ -122,8 +150,46 @@ func ensureNetworkExists(name string) {
 		Type: "bridge",
 	})
 	if err != nil {
-		log.Error("Failed to create network", "name", name, "error", err)
-		return
+		return fmt.Errorf("creating network %s: %w", name, err)
 	}
 	log.Info("Network created successfully", "name", name)
+	return nil
+}
+
+func (b *incusBackend) Stop(name string) error {
+	return stopContainer(name)
+}
+
+func (b *incusBackend) Address(name string, port int) (string, error) {
+	ip, err := getContainerIp(name)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(ip, strconv.Itoa(port)), nil
+}
+
+func (b *incusBackend) Exec(name string, command string) error {
+	c, err := getIncusConnection()
+	if err != nil {
+		return err
+	}
+	return runCmdInContainer(c, name, command)
+}
+
+func (b *incusBackend) List() ([]string, error) {
+	c, err := getIncusConnection()
+	if err != nil {
+		return nil, err
+	}
+	instances, err := c.GetInstances(api.InstanceTypeAny)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, inst := range instances {
+		if inst.Config[instanceConfigKey] != "" {
+			names = append(names, inst.Name)
+		}
+	}
+	return names, nil
 }
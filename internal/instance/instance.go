@@ -8,10 +8,24 @@ import (
 	"github.com/charmbracelet/ssh"
 	"github.com/charmbracelet/wish"
 
+	"ctfsh/internal/config"
 	"ctfsh/internal/db"
 	"ctfsh/internal/util"
 )
 
+var cfg *config.Config
+
+// Init sets the configuration used by the instancer and selects its backend
+func Init(c *config.Config) error {
+	b, err := newBackend(c)
+	if err != nil {
+		return err
+	}
+	cfg = c
+	backend = b
+	return nil
+}
+
 type directTCPChannelData struct {
 	DestAddr   string
 	DestPort   uint32
@@ -24,13 +38,23 @@ func HandleInstanceRequest(s ssh.Session, user *db.User, chal db.Challenge) {
 
 	containerName := fmt.Sprintf("%s-%s", chal.Name, util.RandHex(6))
 	s.Context().SetValue("containerName", containerName)
-	readyChan := make(chan struct{})
+	env := map[string]string{"CTFSH_FLAG": db.FlagFor(chal, user)}
+	track(&Running{Name: containerName, Challenge: chal.Name, Username: user.Username, Started: time.Now(), session: s})
+	readyChan := make(chan error, 1)
+	startDone := make(chan struct{})
 	go func() {
-		StartChallenge(chal.Name, containerName)
-		close(readyChan)
+		readyChan <- backend.Start(chal, containerName, env)
+		close(startDone)
 	}()
 	defer func() {
-		go stopContainer(containerName)
+		go func() {
+			// Let a start still in progress finish so a half-created instance gets cleaned up too
+			<-startDone
+			if err := backend.Stop(containerName); err != nil {
+				log.Error("Failed to stop instance", "name", containerName, "error", err)
+			}
+			untrack(containerName)
+		}()
 	}()
 
 	fmt.Fprintf(s, "\x1b[?25l\n   %s\n\n", chal.Name)
@@ -44,8 +68,13 @@ func HandleInstanceRequest(s ssh.Session, user *db.User, chal db.Challenge) {
 spinner:
 	for {
 		select {
-		case <-readyChan:
+		case err := <-readyChan:
 			ticker.Stop()
+			if err != nil {
+				log.Error("Failed to start instance", "name", containerName, "error", err)
+				fmt.Fprintf(s, "\r %s %s\x1b[?25h\n\n", "✘", "Failed to start instance, please try again later.")
+				return
+			}
 			break spinner
 		case <-s.Context().Done():
 			return
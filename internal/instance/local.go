@@ -0,0 +1,225 @@
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"ctfsh/internal/db"
+)
+
+// localStartTimeout is how long a local instance gets to start listening on its first port
+const localStartTimeout = 30 * time.Second
+
+// localBackend runs the challenge's instance command directly on the server. Every
+// instance gets its own loopback address so challenges can keep their usual ports.
+type localBackend struct {
+	mu        sync.Mutex
+	instances map[string]*localInstance
+	nextAddr  uint32
+}
+
+type localInstance struct {
+	cmd  *exec.Cmd
+	dir  string
+	env  []string
+	host string
+	done chan struct{}
+}
+
+func newLocalBackend() *localBackend {
+	return &localBackend{
+		instances: make(map[string]*localInstance),
+		nextAddr:  1 << 16, // 127.1.0.0, leaving 127.0.0.0/16 to the host
+	}
+}
+
+// allocateAddr returns an unused address in 127.0.0.0/8
+func (b *localBackend) allocateAddr() (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for range 1 << 24 {
+		b.nextAddr++
+		if b.nextAddr >= 1<<24 {
+			b.nextAddr = 1 << 16
+		}
+		n := b.nextAddr
+		if n&0xff == 0 || n&0xff == 0xff {
+			continue
+		}
+		host := fmt.Sprintf("127.%d.%d.%d", n>>16&0xff, n>>8&0xff, n&0xff)
+		inUse := false
+		for _, inst := range b.instances {
+			if inst.host == host {
+				inUse = true
+				break
+			}
+		}
+		if !inUse {
+			return host, nil
+		}
+	}
+	return "", errors.New("no free loopback addresses")
+}
+
+func (b *localBackend) BuildImage(chal db.Challenge) error {
+	if chal.Command == "" {
+		return fmt.Errorf("challenge %s has no instance command for the local backend", chal.Name)
+	}
+	return nil
+}
+
+func (b *localBackend) RemoveImage(chal db.Challenge) error {
+	return nil
+}
+
+func (b *localBackend) Start(chal db.Challenge, name string, env map[string]string) error {
+	if err := b.BuildImage(chal); err != nil {
+		return err
+	}
+	dir := getChallengePath(chal.Name)
+	if dir == "" {
+		return fmt.Errorf("challenge directory for %s not found", chal.Name)
+	}
+	host, err := b.allocateAddr()
+	if err != nil {
+		return err
+	}
+
+	inst := &localInstance{
+		dir:  filepath.Join(dir, chal.BuildDir),
+		host: host,
+		done: make(chan struct{}),
+	}
+	// Only what the challenge needs: the server's own environment holds the flag secret
+	// and database credentials, which a player with code execution could read
+	path := os.Getenv("PATH")
+	if path == "" {
+		path = "/usr/local/bin:/usr/bin:/bin"
+	}
+	inst.env = []string{"PATH=" + path, "HOME=" + inst.dir, "CTFSH_HOST=" + host}
+	if len(chal.Ports) > 0 {
+		inst.env = append(inst.env, "CTFSH_PORT="+strconv.Itoa(chal.Ports[0]))
+	}
+	var ports []string
+	for _, port := range chal.Ports {
+		ports = append(ports, strconv.Itoa(port))
+	}
+	inst.env = append(inst.env, "CTFSH_PORTS="+strings.Join(ports, " "))
+	for k, v := range env {
+		inst.env = append(inst.env, k+"="+v)
+	}
+
+	inst.cmd = exec.Command("sh", "-c", chal.Command)
+	inst.cmd.Dir = inst.dir
+	inst.cmd.Env = inst.env
+	inst.cmd.Stdout = os.Stdout
+	inst.cmd.Stderr = os.Stderr
+	// Run in its own process group so Stop can take down everything the command spawned
+	inst.cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := inst.cmd.Start(); err != nil {
+		return err
+	}
+	go func() {
+		inst.cmd.Wait()
+		close(inst.done)
+	}()
+
+	b.mu.Lock()
+	b.instances[name] = inst
+	b.mu.Unlock()
+
+	if len(chal.Ports) == 0 {
+		return nil
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(chal.Ports[0]))
+	deadline := time.Now().Add(localStartTimeout)
+	for time.Now().Before(deadline) {
+		select {
+		case <-inst.done:
+			b.Stop(name)
+			return fmt.Errorf("instance command exited: %v", inst.cmd.ProcessState)
+		default:
+		}
+		if conn, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+			conn.Close()
+			return nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	b.Stop(name)
+	return fmt.Errorf("instance did not start listening on %s", addr)
+}
+
+func (b *localBackend) get(name string) (*localInstance, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	inst, ok := b.instances[name]
+	if !ok {
+		return nil, fmt.Errorf("instance %s not found", name)
+	}
+	return inst, nil
+}
+
+func (b *localBackend) Stop(name string) error {
+	b.mu.Lock()
+	inst, ok := b.instances[name]
+	delete(b.instances, name)
+	b.mu.Unlock()
+	if !ok {
+		return nil // already stopped, e.g. after a failed start
+	}
+
+	pgid := -inst.cmd.Process.Pid
+	syscall.Kill(pgid, syscall.SIGTERM)
+	select {
+	case <-inst.done:
+	case <-time.After(5 * time.Second):
+		syscall.Kill(pgid, syscall.SIGKILL)
+		<-inst.done
+	}
+	log.Info("Challenge stopped and process killed", "name", name)
+	return nil
+}
+
+func (b *localBackend) Address(name string, port int) (string, error) {
+	inst, err := b.get(name)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(inst.host, strconv.Itoa(port)), nil
+}
+
+func (b *localBackend) Exec(name string, command string) error {
+	inst, err := b.get(name)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = inst.dir
+	cmd.Env = inst.env
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func (b *localBackend) List() ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	names := make([]string, 0, len(b.instances))
+	for name := range b.instances {
+		names = append(names, name)
+	}
+	return names, nil
+}
@@ -0,0 +1,76 @@
+package instance
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+)
+
+// Running is an instance of a challenge, started for a player's session
+type Running struct {
+	Name      string
+	Challenge string
+	Username  string // empty for instances no session knows about, e.g. left over from a crash
+	Started   time.Time
+
+	session ssh.Session
+}
+
+var (
+	runningMu sync.Mutex
+	running   = make(map[string]*Running) // instance name -> instance
+)
+
+func track(r *Running) {
+	runningMu.Lock()
+	running[r.Name] = r
+	runningMu.Unlock()
+}
+
+func untrack(name string) {
+	runningMu.Lock()
+	delete(running, name)
+	runningMu.Unlock()
+}
+
+// ListRunning returns every instance the backend is running, oldest first
+func ListRunning() ([]Running, error) {
+	names, err := backend.List()
+	if err != nil {
+		return nil, err
+	}
+	runningMu.Lock()
+	defer runningMu.Unlock()
+
+	var list []Running
+	for _, name := range names {
+		if r, ok := running[name]; ok {
+			list = append(list, *r)
+		} else {
+			list = append(list, Running{Name: name})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Started.Equal(list[j].Started) {
+			return list[i].Started.Before(list[j].Started)
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+// Kill stops an instance, disconnecting the player using it
+func Kill(name string) error {
+	runningMu.Lock()
+	r, ok := running[name]
+	runningMu.Unlock()
+	if !ok {
+		return backend.Stop(name)
+	}
+	// Closing the session ends HandleInstanceRequest, which stops the instance
+	fmt.Fprint(r.session, "\r\n   Instance stopped by an admin.\x1b[?25h\r\n\n")
+	return r.session.Close()
+}
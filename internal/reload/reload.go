@@ -0,0 +1,140 @@
+// Package reload keeps the challenges in the database, their downloads and
+// instance images in step with the challenge directory while the server runs.
+package reload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ctfsh/internal/config"
+	"ctfsh/internal/db"
+	"ctfsh/internal/download"
+	"ctfsh/internal/instance"
+)
+
+var (
+	mu   sync.Mutex
+	dirs map[string]string // challenge name -> signature of its directory
+)
+
+// Start records the current state of the challenge directory and, unless
+// cfg.WatchInterval is 0, reloads the challenges whenever it changes
+func Start(cfg *config.Config) {
+	mu.Lock()
+	dirs = signatures(cfg, db.GetChallenges())
+	mu.Unlock()
+
+	if cfg.WatchInterval > 0 {
+		go watch(cfg)
+	}
+}
+
+func watch(cfg *config.Config) {
+	last, _ := signature(cfg.ChallengeDir)
+	for range time.Tick(cfg.WatchInterval) {
+		sig, err := signature(cfg.ChallengeDir)
+		if err != nil {
+			log.Printf("Failed to check challenge directory: %v", err)
+			continue
+		}
+		if sig == last {
+			continue
+		}
+		last = sig
+		if _, err := Reload(cfg); err != nil {
+			log.Printf("Failed to reload challenges: %v", err)
+		}
+	}
+}
+
+// Reload loads the challenge directory again and refreshes the downloads and images of
+// every challenge whose definition or files changed, returning their names. Images are
+// rebuilt from scratch, which can take a while; failed builds are reported in the error.
+func Reload(cfg *config.Config) ([]string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	changed := make(map[string]bool)
+	for _, name := range db.LoadChallenges() {
+		changed[name] = true
+	}
+	challenges := db.GetChallenges()
+	current := signatures(cfg, challenges)
+	for name, sig := range current {
+		if dirs[name] != sig {
+			changed[name] = true
+		}
+	}
+	dirs = current
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	names := slices.Sorted(maps.Keys(changed))
+	log.Printf("Reloading challenges: %s", strings.Join(names, ", "))
+	if err := download.RefreshChallengeFS(cfg, names); err != nil {
+		return names, fmt.Errorf("refreshing downloads: %w", err)
+	}
+	var wg sync.WaitGroup
+	var failed []string
+	var failedMu sync.Mutex
+	for _, name := range names {
+		if ch, ok := challenges[name]; ok && len(ch.Ports) > 0 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := instance.RebuildChallengeImage(ch); err != nil {
+					log.Printf("Failed to rebuild image for challenge %s: %v", ch.Name, err)
+					failedMu.Lock()
+					failed = append(failed, fmt.Sprintf("%s: %v", ch.Name, err))
+					failedMu.Unlock()
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return names, fmt.Errorf("rebuilding images failed for %s", strings.Join(failed, "; "))
+	}
+	return names, nil
+}
+
+func signatures(cfg *config.Config, challenges map[string]db.Challenge) map[string]string {
+	sigs := make(map[string]string)
+	for name := range challenges {
+		sig, err := signature(filepath.Join(cfg.ChallengeDir, name))
+		if err != nil {
+			continue // no directory of its own
+		}
+		sigs[name] = sig
+	}
+	return sigs
+}
+
+// signature sums up the names, sizes and modification times of everything under dir
+func signature(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return hex.EncodeToString(h.Sum(nil)), err
+}
@@ -0,0 +1,253 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"ctfsh/internal/config"
+	"ctfsh/internal/db"
+	"ctfsh/internal/download"
+	"ctfsh/internal/instance"
+	"ctfsh/internal/reload"
+)
+
+type adminSection int
+
+const (
+	adminSubmissions adminSection = iota
+	adminTeams
+	adminPlayers
+	adminChallenges
+	adminInstances
+	adminCheats
+)
+
+var adminSections = []string{"Submissions", "Teams", "Players", "Challenges", "Instances", "Cheat Events"}
+
+// adminSubmissionLimit is how many of the latest submissions the console shows
+const adminSubmissionLimit = 500
+
+// adminModel handles the admin console, one section at a time
+type adminModel struct {
+	cfg         *config.Config
+	user        *db.User
+	menuCursor  int
+	section     adminSection
+	cursor      int
+	scoreInput  textinput.Model
+	submissions []db.Submission
+	teams       []db.Team
+	players     []db.Player
+	challenges  []db.Challenge
+	instances   []instance.Running
+	cheats      []db.CheatEvent
+	loadErr     error
+}
+
+// Custom messages for the admin console
+type adjustScoreRequestMsg struct{}
+type reloadDoneMsg struct {
+	changed []string
+	err     error
+}
+
+func newAdminModel(cfg *config.Config, user *db.User) *adminModel {
+	scoreInput := textinput.New()
+	scoreInput.CharLimit = 100
+	scoreInput.Placeholder = "+100 reason"
+	return &adminModel{cfg: cfg, user: user, scoreInput: scoreInput}
+}
+
+// load refreshes the data of the current section
+func (am *adminModel) load() {
+	am.loadErr = nil
+	switch am.section {
+	case adminSubmissions:
+		am.submissions, am.loadErr = db.GetSubmissions(adminSubmissionLimit)
+	case adminTeams:
+		am.teams, am.loadErr = db.GetLiveScoreboard()
+	case adminPlayers:
+		am.players, am.loadErr = db.GetPlayers()
+	case adminChallenges:
+		am.challenges = am.challenges[:0]
+		for _, chal := range db.GetAllChallenges() {
+			am.challenges = append(am.challenges, chal)
+		}
+		sort.Slice(am.challenges, func(i, j int) bool {
+			if am.challenges[i].Category != am.challenges[j].Category {
+				return am.challenges[i].Category < am.challenges[j].Category
+			}
+			return am.challenges[i].Name < am.challenges[j].Name
+		})
+	case adminInstances:
+		am.instances, am.loadErr = instance.ListRunning()
+	case adminCheats:
+		am.cheats, am.loadErr = db.GetCheatEvents()
+	}
+	am.cursor = min(am.cursor, max(am.rows()-1, 0))
+}
+
+func (am *adminModel) rows() int {
+	switch am.section {
+	case adminSubmissions:
+		return len(am.submissions)
+	case adminTeams:
+		return len(am.teams)
+	case adminPlayers:
+		return len(am.players)
+	case adminChallenges:
+		return len(am.challenges)
+	case adminInstances:
+		return len(am.instances)
+	case adminCheats:
+		return len(am.cheats)
+	}
+	return 0
+}
+
+func (am *adminModel) update(msg tea.KeyMsg) (string, string, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keys.Up):
+		if am.cursor > 0 {
+			am.cursor--
+		}
+	case key.Matches(msg, keys.Down):
+		if am.cursor < am.rows()-1 {
+			am.cursor++
+		}
+	case key.Matches(msg, keys.Ban):
+		return am.toggleBan()
+	case key.Matches(msg, keys.Score):
+		if am.section == adminTeams && am.cursor < len(am.teams) {
+			return "", "", func() tea.Msg { return adjustScoreRequestMsg{} }
+		}
+	case key.Matches(msg, keys.Toggle):
+		return am.toggleVisibility()
+	case key.Matches(msg, keys.Reload):
+		if am.section == adminChallenges {
+			return am.reloadChallenges()
+		}
+		am.load()
+	case key.Matches(msg, keys.Kill):
+		return am.killInstance()
+	}
+	return "", "", nil
+}
+
+func (am *adminModel) toggleBan() (string, string, tea.Cmd) {
+	var err error
+	var name string
+	var banned bool
+	switch {
+	case am.section == adminTeams && am.cursor < len(am.teams):
+		team := am.teams[am.cursor]
+		if team.ID == -am.user.ID || (am.user.TeamID != nil && team.ID == *am.user.TeamID) {
+			return "You can't ban your own team.", "error", nil
+		}
+		name, banned = team.Name, !team.Banned
+		if team.ID < 0 {
+			err = db.SetUserBanned(-team.ID, banned)
+		} else {
+			err = db.SetTeamBanned(team.ID, banned)
+		}
+	case am.section == adminPlayers && am.cursor < len(am.players):
+		player := am.players[am.cursor]
+		if player.ID == am.user.ID {
+			return "You can't ban yourself.", "error", nil
+		}
+		name, banned = player.Username, !player.Banned
+		err = db.SetUserBanned(player.ID, banned)
+	default:
+		return "", "", nil
+	}
+	if err != nil {
+		return "Failed to update ban: " + err.Error(), "error", nil
+	}
+	am.load()
+	if banned {
+		return fmt.Sprintf("Banned %s.", name), "success", nil
+	}
+	return fmt.Sprintf("Unbanned %s.", name), "success", nil
+}
+
+// adjustScore parses input such as "+100 solved it on paper" or "-50" and applies it to the selected team
+func (am *adminModel) adjustScore(input string) (string, string) {
+	if am.cursor >= len(am.teams) {
+		return "No team selected.", "error"
+	}
+	amount, note, _ := strings.Cut(strings.TrimSpace(input), " ")
+	points, err := strconv.Atoi(amount)
+	if err != nil || points == 0 {
+		return "Enter a non-zero number of points, optionally followed by a reason.", "error"
+	}
+	team := am.teams[am.cursor]
+	if err := db.AdjustScore(team.ID, points, strings.TrimSpace(note)); err != nil {
+		return "Failed to adjust score: " + err.Error(), "error"
+	}
+	am.load()
+	// Keep the team selected even if it moved on the scoreboard
+	for i, t := range am.teams {
+		if t.ID == team.ID {
+			am.cursor = i
+		}
+	}
+	return fmt.Sprintf("Gave %+d points to %s.", points, team.Name), "success"
+}
+
+func (am *adminModel) toggleVisibility() (string, string, tea.Cmd) {
+	if am.section != adminChallenges || am.cursor >= len(am.challenges) {
+		return "", "", nil
+	}
+	chal := am.challenges[am.cursor]
+	if err := db.SetChallengeDisabled(chal.ID, !chal.Disabled); err != nil {
+		return "Failed to update challenge: " + err.Error(), "error", nil
+	}
+	if err := download.RefreshChallengeFS(am.cfg, []string{chal.Name}); err != nil {
+		return "Failed to update downloads: " + err.Error(), "error", nil
+	}
+	am.load()
+	if chal.Disabled {
+		return fmt.Sprintf("%s is visible to players again.", chal.Name), "success", nil
+	}
+	return fmt.Sprintf("%s is hidden from players.", chal.Name), "success", nil
+}
+
+// reloadChallenges reloads in the background, as rebuilding images can take minutes
+func (am *adminModel) reloadChallenges() (string, string, tea.Cmd) {
+	return "Reloading challenges...", "success", func() tea.Msg {
+		changed, err := reload.Reload(am.cfg)
+		return reloadDoneMsg{changed, err}
+	}
+}
+
+func (am *adminModel) reloadDone(msg reloadDoneMsg) (string, string) {
+	am.load()
+	if msg.err != nil {
+		if len(msg.changed) > 0 {
+			return "Reloaded " + strings.Join(msg.changed, ", ") + ", but " + msg.err.Error(), "error"
+		}
+		return "Failed to reload challenges: " + msg.err.Error(), "error"
+	}
+	if len(msg.changed) == 0 {
+		return "No challenges changed.", "success"
+	}
+	return "Reloaded " + strings.Join(msg.changed, ", ") + ".", "success"
+}
+
+func (am *adminModel) killInstance() (string, string, tea.Cmd) {
+	if am.section != adminInstances || am.cursor >= len(am.instances) {
+		return "", "", nil
+	}
+	name := am.instances[am.cursor].Name
+	if err := instance.Kill(name); err != nil {
+		return "Failed to stop instance: " + err.Error(), "error", nil
+	}
+	am.load()
+	return fmt.Sprintf("Stopped %s.", name), "success", nil
+}
@@ -0,0 +1,43 @@
+package ui
+
+import (
+	"log"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"ctfsh/internal/db"
+)
+
+// announcementsModel handles the announcements history
+type announcementsModel struct {
+	items  []db.Announcement
+	offset int // index of the first announcement shown
+}
+
+func newAnnouncementsModel() *announcementsModel {
+	return &announcementsModel{}
+}
+
+func (am *announcementsModel) load() {
+	items, err := db.GetAnnouncements()
+	if err != nil {
+		log.Printf("Failed to load announcements: %v\n", err)
+		return
+	}
+	am.items = items
+	am.offset = min(am.offset, max(len(am.items)-1, 0))
+}
+
+func (am *announcementsModel) update(msg tea.KeyMsg) {
+	switch {
+	case key.Matches(msg, keys.Up):
+		if am.offset > 0 {
+			am.offset--
+		}
+	case key.Matches(msg, keys.Down):
+		if am.offset < len(am.items)-1 {
+			am.offset++
+		}
+	}
+}
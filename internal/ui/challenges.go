@@ -1,7 +1,11 @@
 package ui
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"sort"
+	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
@@ -15,6 +19,7 @@ type challengeWrapper struct {
 	db.Challenge
 	solved bool
 	solver string
+	locked bool
 }
 
 // challengeModel handles the challenge list and detail views
@@ -27,6 +32,15 @@ type challengeModel struct {
 	expandedCats map[string]bool
 	flagInput    textinput.Model
 	teamSolvers  map[int]string // challenge_id -> username
+	bloods       map[int][]db.Blood
+	progress     *db.Progress
+	hints        []db.Hint   // hints of the selected challenge
+	solvers      []db.Solver // who solved the selected challenge, in order
+	lockedUntil  time.Time   // when flags for the selected challenge are accepted again after too many wrong ones
+	hintCursor   int
+	nextRelease  time.Time // when the next hidden challenge comes out, zero if none
+	generation   int64     // db.ChallengesGeneration the challenges were loaded at
+	solvesSeen   bool      // a solve came in, so solve counts and values are out of date
 }
 
 // Custom messages for challenge view
@@ -44,20 +58,14 @@ func newChallengeModel(user *db.User) *challengeModel {
 	}
 
 	// Load challenges and categories
-	rawChallenges := db.GetChallenges()
-	cm.challenges = make(map[string]challengeWrapper)
-	for name, chal := range rawChallenges {
-		cm.challenges[name] = challengeWrapper{Challenge: chal}
-	}
-	cm.categories = db.GetChallengeCategories()
+	cm.loadChallenges()
 
 	// Initialize expanded state for categories
 	for _, category := range cm.categories {
 		cm.expandedCats[category] = false
 	}
 
-	// Load solved status
-	cm.loadSolvedStatus()
+	cm.bloods, _ = db.GetChallengeBloods()
 
 	// Load team solvers if user is on a team
 	if user.TeamID != nil {
@@ -68,15 +76,61 @@ func newChallengeModel(user *db.User) *challengeModel {
 	return cm
 }
 
+// loadChallenges loads the released challenges, noting when the next one comes out
+func (cm *challengeModel) loadChallenges() {
+	cm.generation = db.ChallengesGeneration()
+	cm.solvesSeen = false
+	cm.challenges = make(map[string]challengeWrapper)
+	cm.nextRelease = time.Time{}
+	for name, chal := range db.GetChallenges() {
+		if !chal.Released() {
+			if release := chal.ReleaseTime(); cm.nextRelease.IsZero() || release.Before(cm.nextRelease) {
+				cm.nextRelease = release
+			}
+			continue
+		}
+		cm.challenges[name] = challengeWrapper{Challenge: chal}
+	}
+	cm.categories = db.GetChallengeCategories()
+	cm.loadSolvedStatus()
+	if chal, ok := cm.challenges[cm.selectedChal.Name]; ok {
+		cm.selectedChal = chal
+	}
+}
+
+// stale reports whether the challenges changed on disk, were solved or one got released since they were loaded
+func (cm *challengeModel) stale(now time.Time) bool {
+	if cm.solvesSeen || cm.generation != db.ChallengesGeneration() {
+		return true
+	}
+	return !cm.nextRelease.IsZero() && !now.Before(cm.nextRelease)
+}
+
+// loadSolvedStatus refreshes which challenges are solved, and which are still locked behind prerequisites
 func (cm *challengeModel) loadSolvedStatus() {
-	solvedMap, _ := db.GetChallengesSolvedByUser(cm.user.ID)
+	solvedMap, _ := db.GetSolvedChallenges(cm.user)
+	if progress, err := db.GetProgress(cm.user); err == nil {
+		cm.progress = progress
+	}
 	for name, chal := range cm.challenges {
 		if solvedMap[chal.ID] {
-			solvedChal := chal
-			solvedChal.solved = true
-			cm.challenges[name] = solvedChal
+			chal.solved = true
+		}
+		if cm.progress != nil {
+			chal.locked = !cm.progress.Unlocked(chal.Challenge)
+		}
+		cm.challenges[name] = chal
+	}
+}
+
+// blood returns the blood the user's team took on a challenge, if any
+func (cm *challengeModel) blood(chalID int) (db.Blood, bool) {
+	for _, blood := range cm.bloods[chalID] {
+		if blood.By(cm.user) {
+			return blood, true
 		}
 	}
+	return db.Blood{}, false
 }
 
 func (cm *challengeModel) buildChallengeRenderList() []any {
@@ -91,18 +145,21 @@ func (cm *challengeModel) buildChallengeRenderList() []any {
 		}
 	}
 
-	// Sort the challenges by point value within each category (break ties by name)
+	// Sort the challenges by current point value within each category (break ties by name)
 	for cat, challenges := range categoryMap {
 		sort.Slice(challenges, func(i, j int) bool {
-			if challenges[i].Points == challenges[j].Points {
+			if challenges[i].Value == challenges[j].Value {
 				return challenges[i].Name < challenges[j].Name
 			}
-			return challenges[i].Points > challenges[j].Points
+			return challenges[i].Value > challenges[j].Value
 		})
 		categoryMap[cat] = challenges
 	}
 
 	for _, category := range cm.categories {
+		if len(categoryMap[category]) == 0 {
+			continue // nothing released yet
+		}
 		items = append(items, categoryListItem{
 			name:       category,
 			total:      len(categoryMap[category]),
@@ -140,6 +197,7 @@ func (cm *challengeModel) update(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			cm.expandedCats[cat.name] = !cm.expandedCats[cat.name]
 		} else if chal, ok := selectedItem.(challengeWrapper); ok {
 			cm.selectedChal = chal
+			cm.loadSolvers()
 			return nil, func() tea.Msg { return switchToDetailView{} }
 		}
 	}
@@ -156,11 +214,62 @@ func (cm *challengeModel) update(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return nil, nil
 }
 
+func (cm *challengeModel) loadSolvers() {
+	solvers, err := db.GetChallengeSolvers(cm.selectedChal.ID)
+	if err != nil {
+		log.Printf("Failed to load solvers of %s: %v\n", cm.selectedChal.Name, err)
+	}
+	cm.solvers = solvers
+}
+
+func (cm *challengeModel) loadLockout() {
+	until, err := db.GetLockout(cm.user, cm.selectedChal.ID)
+	if err != nil {
+		log.Printf("Failed to look up lockout: %v\n", err)
+	}
+	cm.lockedUntil = until
+}
+
+func (cm *challengeModel) loadHints() {
+	hints, err := db.GetHints(cm.selectedChal.ID, cm.user)
+	if err != nil {
+		hints = nil
+	}
+	cm.hints = hints
+	if cm.hintCursor >= len(cm.hints) {
+		cm.hintCursor = max(len(cm.hints)-1, 0)
+	}
+}
+
+func (cm *challengeModel) selectedHint() (db.Hint, bool) {
+	if cm.hintCursor >= len(cm.hints) {
+		return db.Hint{}, false
+	}
+	return cm.hints[cm.hintCursor], true
+}
+
+func (cm *challengeModel) unlockHint() (string, string) {
+	hint, ok := cm.selectedHint()
+	if !ok {
+		return "", ""
+	}
+	if err := db.UnlockHint(cm.user, hint.ID); err != nil {
+		return err.Error(), "error"
+	}
+	cm.loadHints()
+	return fmt.Sprintf("Hint %d unlocked.", hint.Position), "success"
+}
+
 func (cm *challengeModel) submitFlag(flag string) (string, string) {
 	if flag == "" {
 		return "", ""
 	}
 	correct, err := db.SubmitFlag(cm.user.ID, cm.selectedChal.ID, flag)
+	cm.loadLockout()
+	var lockout *db.LockoutError
+	if errors.As(err, &lockout) {
+		return "Too many wrong flags.", "error"
+	}
 	if err != nil {
 		return err.Error(), "error"
 	}
@@ -182,10 +291,12 @@ func (cm *challengeModel) submitFlag(flag string) (string, string) {
 
 		// Refresh all challenge and solver state
 		cm.loadSolvedStatus()
+		cm.bloods, _ = db.GetChallengeBloods()
 		if cm.user.TeamID != nil {
 			solvers, _ := db.GetTeamChallengeSolvers(*cm.user.TeamID)
 			cm.teamSolvers = solvers
 		}
+		cm.loadSolvers()
 
 		return "Correct! Flag accepted.", "success"
 	}
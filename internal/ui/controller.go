@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
@@ -11,10 +12,13 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/ssh"
 	"github.com/charmbracelet/wish"
+	"github.com/charmbracelet/wish/bubbletea"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/muesli/termenv"
 
+	"ctfsh/internal/config"
 	"ctfsh/internal/db"
+	"ctfsh/internal/events"
 	"ctfsh/internal/instance"
 )
 
@@ -22,8 +26,24 @@ func (m model) Quit() tea.Cmd {
 	return tea.Quit
 }
 
+// clockTickMsg redraws countdowns and picks up challenges as they are released or reloaded
+type clockTickMsg time.Time
+
+func clockTick() tea.Cmd {
+	return tea.Every(time.Second, func(t time.Time) tea.Msg { return clockTickMsg(t) })
+}
+
+// toastDuration is how long a notification stays on top of every view
+const toastDuration = 15 * time.Second
+
+// showToast shows a notification on top of every view for a while
+func (m *model) showToast(text string) {
+	m.toast = text
+	m.toastUntil = time.Now().Add(toastDuration)
+}
+
 func (m model) Init() tea.Cmd {
-	return textinput.Blink
+	return tea.Batch(textinput.Blink, clockTick())
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -34,6 +54,52 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.help.Width = msg.Width
 		return m, nil
 
+	case clockTickMsg:
+		if m.challenges != nil && m.challenges.stale(time.Time(msg)) {
+			m.challenges.loadChallenges()
+			if m.state == challengeDetailView {
+				m.challenges.loadSolvers()
+			}
+		}
+		if m.toast != "" && time.Time(msg).After(m.toastUntil) {
+			m.toast = ""
+		}
+		return m, clockTick()
+
+	case events.Announcement:
+		m.showToast(fmt.Sprintf("📣 %s: %s", msg.Author, msg.Message))
+		if m.state == announcementsView {
+			m.announcements.load()
+		}
+		return m, nil
+
+	case events.Solve:
+		if m.user == nil {
+			return m, nil
+		}
+		switch m.state {
+		case scoreboardView:
+			m.scoreboard.loadScoreboard()
+		case challengeView, challengeDetailView:
+			// Solve counts and dynamic values changed. Reload on the next tick, so a burst
+			// of solves costs every open session one reload rather than one each.
+			m.challenges.solvesSeen = true
+		}
+		if msg.UserID != m.user.ID && msg.TeamID != nil && m.user.TeamID != nil && *msg.TeamID == *m.user.TeamID {
+			m.showToast(fmt.Sprintf("🎉 %s solved %s", msg.Username, msg.Challenge))
+			m.challenges.loadSolvedStatus()
+			if solvers, err := db.GetTeamChallengeSolvers(*m.user.TeamID); err == nil {
+				m.challenges.teamSolvers = solvers
+			}
+		}
+		return m, nil
+
+	case events.ScoresChanged:
+		if m.user != nil && m.state == scoreboardView {
+			m.scoreboard.loadScoreboard()
+		}
+		return m, nil
+
 	case switchToDetailView:
 		m.state = challengeDetailView
 		return m, nil
@@ -44,12 +110,17 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.inputTitle = fmt.Sprintf("Submit Flag - %s", m.challenges.selectedChal.Name)
 		m.inputModel = &m.challenges.flagInput
 		m.inputModel.Focus()
+		m.challenges.loadLockout()
 		m.message = ""
 		m.onSubmit = func(flag string) (string, string) {
 			return m.challenges.submitFlag(flag)
 		}
 		return m, nil
 
+	case reloadDoneMsg:
+		m.message, m.messageType = m.admin.reloadDone(msg)
+		return m, nil
+
 	case teamErrorMsg:
 		m.message = msg.message
 		m.messageType = "error"
@@ -76,6 +147,23 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case adjustScoreRequestMsg:
+		m.state = genericInputView
+		m.onBackState = adminSectionView
+		m.inputModel = &m.admin.scoreInput
+		m.inputModel.Focus()
+		m.message = ""
+		m.inputTitle = fmt.Sprintf("Adjust Score - %s", m.admin.teams[m.admin.cursor].Name)
+		m.onSubmit = func(input string) (string, string) {
+			return m.admin.adjustScore(input)
+		}
+		return m, nil
+
+	case viewTeamDetailMsg:
+		m.state = teamDetailView
+		m.teamDetail.load(msg.team, m.scoreboard.teams)
+		return m, nil
+
 	case viewTeamMembersMsg:
 		m.state = teamMembersView
 		m.teamMembers.loadTeamMembers() // Load team members data
@@ -123,6 +211,18 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.updateConfirmDeleteTeamView(msg)
 		case promptJoinTeamView:
 			return m.updatePromptJoinTeamView(msg)
+		case hintsView:
+			return m.updateHintsView(msg)
+		case confirmHintView:
+			return m.updateConfirmHintView(msg)
+		case adminView:
+			return m.updateAdminView(msg)
+		case adminSectionView:
+			return m.updateAdminSectionView(msg)
+		case announcementsView:
+			return m.updateAnnouncementsView(msg)
+		case teamDetailView:
+			return m.updateTeamDetailView(msg)
 		}
 	}
 	return m, nil
@@ -164,12 +264,35 @@ func (m model) View() string {
 		return strings.Repeat("\n", verticalPad) + centered
 	case promptJoinTeamView:
 		s = m.renderPromptJoinTeamView()
+	case hintsView:
+		s = m.renderHintsView()
+	case confirmHintView:
+		msg := m.renderConfirmHintView()
+		centered := lipgloss.NewStyle().Width(m.width).Align(lipgloss.Center).Render(msg)
+		verticalPad := max((m.height-1)/2, 0)
+		return strings.Repeat("\n", verticalPad) + centered
+	case adminView:
+		s = m.renderAdminView()
+	case adminSectionView:
+		s = m.renderAdminSectionView()
+	case announcementsView:
+		s = m.renderAnnouncementsView()
+	case teamDetailView:
+		s = m.renderTeamDetailView()
 	default:
 		s = "Unknown view state."
 	}
 
+	if m.toast != "" {
+		s = toastStyle.Render(m.toast) + "\n\n" + s
+	}
+
 	// Always horizontally center the window based on current m.width
-	window := windowStyle.Width(m.width / 2).MaxWidth(m.width - 4).Render(s)
+	width := m.width / 2
+	if m.state == scoreboardView && m.scoreboard.showGraph {
+		width = graphWindowWidth(m.width)
+	}
+	window := windowStyle.Width(width).MaxWidth(m.width - 4).Render(s)
 	windowLines := strings.Split(window, "\n")
 	maxLineWidth := 0
 	for _, line := range windowLines {
@@ -225,6 +348,15 @@ func (m model) updateAuthView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// menuOptions lists the main menu entries, with the admin console for admins
+func (m model) menuOptions() []string {
+	options := []string{"Challenges", "Scoreboard", "Team Management", "Announcements"}
+	if m.admin != nil {
+		options = append(options, "Admin")
+	}
+	return options
+}
+
 func (m model) updateMenuView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch {
 	case key.Matches(msg, keys.Back):
@@ -237,19 +369,19 @@ func (m model) updateMenuView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.menuCursor--
 		}
 	case key.Matches(msg, keys.Down):
-		if m.menuCursor < 2 {
+		if m.menuCursor < len(m.menuOptions())-1 {
 			m.menuCursor++
 		}
 	case key.Matches(msg, keys.Select):
-		switch m.menuCursor {
-		case 0:
+		switch m.menuOptions()[m.menuCursor] {
+		case "Challenges":
 			m.state = challengeView
 			m.challenges.cursor = 0
 			m.challenges.loadSolvedStatus() // Refresh challenge solved status
-		case 1:
+		case "Scoreboard":
 			m.state = scoreboardView
 			m.scoreboard.loadScoreboard() // Refresh scoreboard data
-		case 2:
+		case "Team Management":
 			m.state = teamView
 			m.team.cursor = 0
 			m.message = ""
@@ -260,6 +392,14 @@ func (m model) updateMenuView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 					m.team.teamJoinCode = code
 				}
 			}
+		case "Announcements":
+			m.state = announcementsView
+			m.toast = ""
+			m.announcements.offset = 0
+			m.announcements.load()
+		case "Admin":
+			m.state = adminView
+			m.message = ""
 		}
 	}
 	return m, nil
@@ -295,13 +435,21 @@ func (m model) updateChallengeDetailView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.state = challengeView
 	case key.Matches(msg, keys.Help):
 		m.showHelp = !m.showHelp
+	case key.Matches(msg, keys.Hints):
+		if len(m.challenges.selectedChal.Hints) > 0 && !m.challenges.selectedChal.locked {
+			m.state = hintsView
+			m.challenges.hintCursor = 0
+			m.challenges.loadHints()
+			m.message = ""
+		}
 	case key.Matches(msg, keys.Select):
-		if !m.challenges.selectedChal.solved {
+		if !m.challenges.selectedChal.solved && !m.challenges.selectedChal.locked {
 			m.state = genericInputView
 			m.onBackState = challengeDetailView
 			m.inputTitle = fmt.Sprintf("Submit Flag - %s", m.challenges.selectedChal.Name)
 			m.inputModel = &m.challenges.flagInput
 			m.inputModel.Focus()
+			m.challenges.loadLockout()
 			m.message = ""
 			m.onSubmit = func(flag string) (string, string) {
 				return m.challenges.submitFlag(flag)
@@ -311,8 +459,47 @@ func (m model) updateChallengeDetailView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+func (m model) updateHintsView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keys.Back):
+		m.state = challengeDetailView
+		m.message = ""
+	case key.Matches(msg, keys.Help):
+		m.showHelp = !m.showHelp
+	case key.Matches(msg, keys.Up):
+		if m.challenges.hintCursor > 0 {
+			m.challenges.hintCursor--
+		}
+	case key.Matches(msg, keys.Down):
+		if m.challenges.hintCursor < len(m.challenges.hints)-1 {
+			m.challenges.hintCursor++
+		}
+	case key.Matches(msg, keys.Select):
+		if hint, ok := m.challenges.selectedHint(); ok && !hint.Unlocked {
+			m.state = confirmHintView
+			m.message = ""
+		}
+	}
+	return m, nil
+}
+
+func (m model) updateConfirmHintView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if key.Matches(msg, keys.Back) {
+		m.state = hintsView
+		return m, nil
+	}
+	switch msg.String() {
+	case "y", "Y":
+		m.message, m.messageType = m.challenges.unlockHint()
+		m.state = hintsView
+	case "n", "N":
+		m.state = hintsView
+	}
+	return m, nil
+}
+
 func (m model) updateScoreboardView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	m.scoreboard.update(msg)
+	cmd := m.scoreboard.update(msg)
 	m.inputFocus = m.scoreboard.searchMode
 
 	switch {
@@ -323,8 +510,20 @@ func (m model) updateScoreboardView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case key.Matches(msg, keys.Help):
 		m.showHelp = !m.showHelp
 	}
-	return m, nil
+	return m, cmd
+
+}
 
+func (m model) updateTeamDetailView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keys.Back):
+		m.state = scoreboardView
+	case key.Matches(msg, keys.Help):
+		m.showHelp = !m.showHelp
+	default:
+		m.teamDetail.update(msg)
+	}
+	return m, nil
 }
 
 func (m model) updateTeamView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
@@ -376,6 +575,61 @@ func (m model) updateTeamMembersView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+func (m model) updateAnnouncementsView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keys.Back):
+		m.state = menuView
+	case key.Matches(msg, keys.Help):
+		m.showHelp = !m.showHelp
+	default:
+		m.announcements.update(msg)
+	}
+	return m, nil
+}
+
+func (m model) updateAdminView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keys.Back):
+		m.state = menuView
+	case key.Matches(msg, keys.Help):
+		m.showHelp = !m.showHelp
+	case key.Matches(msg, keys.Up):
+		if m.admin.menuCursor > 0 {
+			m.admin.menuCursor--
+		}
+	case key.Matches(msg, keys.Down):
+		if m.admin.menuCursor < len(adminSections)-1 {
+			m.admin.menuCursor++
+		}
+	case key.Matches(msg, keys.Select):
+		m.admin.section = adminSection(m.admin.menuCursor)
+		m.admin.cursor = 0
+		m.admin.load()
+		m.state = adminSectionView
+		m.message = ""
+	}
+	return m, nil
+}
+
+func (m model) updateAdminSectionView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keys.Back):
+		m.state = adminView
+		m.message = ""
+		return m, nil
+	case key.Matches(msg, keys.Help):
+		m.showHelp = !m.showHelp
+		return m, nil
+	}
+
+	message, messageType, cmd := m.admin.update(msg)
+	if message != "" {
+		m.message = message
+		m.messageType = messageType
+	}
+	return m, cmd
+}
+
 func (m model) updateGenericInputView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 
@@ -485,12 +739,29 @@ func (m model) updatePromptJoinTeamView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-// teaHandler is responsible for the entire lifecycle of a user session,
+// NewProgramHandler returns the handler responsible for the entire lifecycle of a user session,
 // including authentication, user creation, and initializing the TUI.
-func TeaHandler(s ssh.Session) (tea.Model, []tea.ProgramOption) {
+// Programs are subscribed to events so announcements reach them while they run.
+func NewProgramHandler(cfg *config.Config) bubbletea.ProgramHandler {
+	return func(s ssh.Session) *tea.Program {
+		m, opts := teaHandler(cfg, s)
+		if m == nil {
+			return nil
+		}
+		p := tea.NewProgram(m, append(opts, bubbletea.MakeOptions(s)...)...)
+		unsubscribe := events.Subscribe(p)
+		go func() {
+			<-s.Context().Done()
+			unsubscribe()
+		}()
+		return p
+	}
+}
+
+func teaHandler(cfg *config.Config, s ssh.Session) (tea.Model, []tea.ProgramOption) {
 	pty, _, active := s.Pty()
 	if !active {
-		wish.Fatalln(s, "No PTY requested.")
+		wish.Fatalln(s, "No PTY requested. Run `ssh <host> help` for the non-interactive commands.")
 		return nil, nil
 	}
 
@@ -515,8 +786,12 @@ func TeaHandler(s ssh.Session) (tea.Model, []tea.ProgramOption) {
 	//  Check if a user exists with the provided public key.
 	user, err := authenticateUser(sshKeyStr)
 	if err == nil {
+		if user.Banned {
+			wish.Fatalln(s, "You have been banned from this CTF.")
+			return nil, nil
+		}
 		// User found with this key. Log them in.
-		m := initialModel(user)
+		m := initialModel(cfg, user)
 		m.width = pty.Window.Width
 		m.height = pty.Window.Height
 
@@ -524,6 +799,15 @@ func TeaHandler(s ssh.Session) (tea.Model, []tea.ProgramOption) {
 		if user.Username != sshUser {
 			chal, isChal := db.GetChallenges()[sshUser]
 			if isChal {
+				if !chal.Released() {
+					wish.Fatalln(s, "This challenge has not been released yet.")
+					return nil, nil
+				}
+				progress, err := db.GetProgress(user)
+				if err != nil || !progress.Unlocked(chal) {
+					wish.Fatalln(s, "This challenge is locked, solve its prerequisites first.")
+					return nil, nil
+				}
 				instance.HandleInstanceRequest(s, user, chal)
 				return nil, nil
 			}
@@ -539,7 +823,7 @@ func TeaHandler(s ssh.Session) (tea.Model, []tea.ProgramOption) {
 
 	// If key not found, start the registration flow.
 	log.Printf("New public key detected. Starting registration flow.")
-	m := newRegistrationModel(sshKeyStr, joinPrompt)
+	m := newRegistrationModel(cfg, sshKeyStr, joinPrompt)
 	m.width = pty.Window.Width
 	m.height = pty.Window.Height
 	return m, []tea.ProgramOption{tea.WithAltScreen()}
@@ -0,0 +1,138 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"ctfsh/internal/db"
+)
+
+// graphTeams is how many of the top teams the score graph shows
+const graphTeams = 10
+
+// graphColors tells the lines of the score graph apart, one per team
+var graphColors = []lipgloss.Color{"205", "86", "220", "39", "196", "46", "171", "208", "51", "250"}
+
+// graphSeries is one team's line in the score graph
+type graphSeries struct {
+	name   string
+	points []db.ScorePoint
+	color  lipgloss.Color
+}
+
+// scoreAt returns the score a timeline had reached at t
+func scoreAt(points []db.ScorePoint, t time.Time) int {
+	i := sort.Search(len(points), func(i int) bool { return points[i].Time.After(t) })
+	if i == 0 {
+		return 0
+	}
+	return points[i-1].Score
+}
+
+// brailleDots maps a dot's position within a 2x4 braille cell to its bit
+var brailleDots = [4][2]rune{{0x01, 0x08}, {0x02, 0x10}, {0x04, 0x20}, {0x40, 0x80}}
+
+// renderScoreGraph draws the series as braille step lines from start to end, in width x height cells including the axes
+func renderScoreGraph(series []graphSeries, start, end time.Time, width, height int) string {
+	minScore, maxScore := 0, 1
+	for _, s := range series {
+		for _, p := range s.points {
+			minScore, maxScore = min(minScore, p.Score), max(maxScore, p.Score)
+		}
+	}
+	top, bottom := fmt.Sprint(maxScore), fmt.Sprint(minScore)
+	labelWidth := max(len(top), len(bottom))
+	plotWidth := max(width-labelWidth-1, 10)
+
+	dots := make([][]rune, height)
+	colors := make([][]lipgloss.Color, height)
+	for y := range dots {
+		dots[y] = make([]rune, plotWidth)
+		colors[y] = make([]lipgloss.Color, plotWidth)
+	}
+	set := func(x, y int, color lipgloss.Color) {
+		dots[y/4][x/2] |= brailleDots[y%4][x%2]
+		colors[y/4][x/2] = color
+	}
+
+	dotsWide, dotsHigh := plotWidth*2, height*4
+	span := end.Sub(start)
+	// Draw the lowest ranked team first so the leaders stay on top
+	for i := len(series) - 1; i >= 0; i-- {
+		s := series[i]
+		prev := -1
+		for x := range dotsWide {
+			t := start.Add(span * time.Duration(x) / time.Duration(dotsWide-1))
+			y := dotsHigh - 1 - (scoreAt(s.points, t)-minScore)*(dotsHigh-1)/(maxScore-minScore)
+			if prev < 0 {
+				prev = y
+			}
+			for step := min(prev, y); step <= max(prev, y); step++ {
+				set(x, step, s.color)
+			}
+			prev = y
+		}
+	}
+
+	var b strings.Builder
+	for y := range dots {
+		label, axis := "", "│"
+		switch y {
+		case 0:
+			label, axis = top, "┤"
+		case height - 1:
+			label, axis = bottom, "┤"
+		}
+		b.WriteString(strings.Repeat(" ", labelWidth-len(label)) + label + helpStyle.Render(axis))
+		for x := 0; x < plotWidth; {
+			// Render runs of cells in the same color together
+			run := x
+			for run < plotWidth && colors[y][run] == colors[y][x] {
+				run++
+			}
+			var cells strings.Builder
+			for _, dot := range dots[y][x:run] {
+				if dot == 0 {
+					cells.WriteRune(' ')
+				} else {
+					cells.WriteRune(0x2800 + dot)
+				}
+			}
+			b.WriteString(lipgloss.NewStyle().Foreground(colors[y][x]).Render(cells.String()))
+			x = run
+		}
+		b.WriteString("\n")
+	}
+
+	layout := "15:04"
+	if span > 24*time.Hour {
+		layout = "Jan 2 15:04"
+	}
+	from, to := start.Local().Format(layout), end.Local().Format(layout)
+	b.WriteString(helpStyle.Render(strings.Repeat(" ", labelWidth)+"└"+strings.Repeat("─", plotWidth)) + "\n")
+	b.WriteString(helpStyle.Render(strings.Repeat(" ", labelWidth+1)+from+strings.Repeat(" ", max(plotWidth-len(from)-len(to), 1))+to) + "\n")
+	return b.String()
+}
+
+// renderGraphLegend lists the teams of the graph in their colors, wrapped to width
+func renderGraphLegend(series []graphSeries, scores []int, width int) string {
+	var b strings.Builder
+	lineWidth := 0
+	for i, s := range series {
+		item := fmt.Sprintf("● %s (%d)", s.name, scores[i])
+		if lineWidth > 0 && lineWidth+2+lipgloss.Width(item) > width {
+			b.WriteString("\n")
+			lineWidth = 0
+		} else if lineWidth > 0 {
+			b.WriteString("  ")
+			lineWidth += 2
+		}
+		b.WriteString(lipgloss.NewStyle().Foreground(s.color).Render(item))
+		lineWidth += lipgloss.Width(item)
+	}
+	return b.String()
+}
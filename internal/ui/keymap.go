@@ -15,6 +15,12 @@ type keyMap struct {
 	Quit   key.Binding
 	Help   key.Binding
 	Tab    key.Binding
+	Hints  key.Binding
+	Ban    key.Binding
+	Score  key.Binding
+	Toggle key.Binding
+	Reload key.Binding
+	Kill   key.Binding
 }
 
 func (k keyMap) ShortHelp() []key.Binding {
@@ -38,4 +44,10 @@ var keys = keyMap{
 	Quit:   key.NewBinding(key.WithKeys("ctrl+c"), key.WithHelp("ctrl+c", "quit")),
 	Help:   key.NewBinding(key.WithKeys("?"), key.WithHelp("?", "toggle help")),
 	Tab:    key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "switch view")),
+	Hints:  key.NewBinding(key.WithKeys("h"), key.WithHelp("h", "hints")),
+	Ban:    key.NewBinding(key.WithKeys("b"), key.WithHelp("b", "ban/unban")),
+	Score:  key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "adjust score")),
+	Toggle: key.NewBinding(key.WithKeys("v"), key.WithHelp("v", "show/hide")),
+	Reload: key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "reload")),
+	Kill:   key.NewBinding(key.WithKeys("x"), key.WithHelp("x", "stop instance")),
 }
@@ -1,9 +1,12 @@
 package ui
 
 import (
+	"time"
+
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/textinput"
 
+	"ctfsh/internal/config"
 	"ctfsh/internal/db"
 )
 
@@ -21,6 +24,12 @@ const (
 	flagResultView
 	confirmDeleteTeamView
 	promptJoinTeamView
+	hintsView
+	confirmHintView
+	adminView
+	adminSectionView
+	announcementsView
+	teamDetailView
 )
 
 type joinPromptState int
@@ -46,6 +55,8 @@ type categoryListItem struct {
 
 // Main model that coordinates all views
 type model struct {
+	cfg *config.Config
+
 	// User data
 	user   *db.User
 	sshKey string // For registration flow
@@ -62,6 +73,8 @@ type model struct {
 	showHelp    bool
 	confirmQuit bool
 	inputFocus  bool
+	toast       string // latest notification, shown on top of every view until toastUntil
+	toastUntil  time.Time
 
 	// Registration flow
 	usernameInput textinput.Model
@@ -77,15 +90,19 @@ type model struct {
 	onBackState sessionState
 
 	// View-specific models
-	challenges  *challengeModel
-	scoreboard  *scoreboardModel
-	team        *teamModel
-	teamMembers *teamMembersModel
+	challenges    *challengeModel
+	scoreboard    *scoreboardModel
+	teamDetail    *teamDetailModel
+	team          *teamModel
+	teamMembers   *teamMembersModel
+	announcements *announcementsModel
+	admin         *adminModel
 }
 
 // Initialize a new model for authenticated users
-func initialModel(user *db.User) model {
+func initialModel(cfg *config.Config, user *db.User) model {
 	m := model{
+		cfg:   cfg,
 		user:  user,
 		state: menuView,
 		help:  help.New(),
@@ -95,12 +112,13 @@ func initialModel(user *db.User) model {
 }
 
 // Initialize a new model for registration flow
-func newRegistrationModel(sshKey string, joinPrompt joinPromptInfo) model {
+func newRegistrationModel(cfg *config.Config, sshKey string, joinPrompt joinPromptInfo) model {
 	unInput := textinput.New()
 	unInput.Focus()
 	unInput.CharLimit = 32
 
 	return model{
+		cfg:           cfg,
 		sshKey:        sshKey,
 		state:         authView,
 		usernameInput: unInput,
@@ -113,7 +131,12 @@ func newRegistrationModel(sshKey string, joinPrompt joinPromptInfo) model {
 func (m *model) finishInitialization() {
 	// Initialize view-specific models
 	m.challenges = newChallengeModel(m.user)
-	m.scoreboard = newScoreboardModel()
+	m.scoreboard = newScoreboardModel(m.user)
+	m.teamDetail = newTeamDetailModel(m.user)
 	m.team = newTeamModel(m.user)
 	m.teamMembers = newTeamMembersModel(m.user)
+	m.announcements = newAnnouncementsModel()
+	if m.user.IsAdmin() {
+		m.admin = newAdminModel(m.cfg, m.user)
+	}
 }
@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"log"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/key"
@@ -14,14 +15,19 @@ type scoreboardTeam struct {
 	place int
 }
 type scoreboardModel struct {
+	user       *db.User
 	teams      []scoreboardTeam
+	frozen     bool // players are shown the standings from the freeze
 	cursor     int
 	search     string
 	searchMode bool
+	showGraph  bool
+	timelines  map[int][]db.ScorePoint
 }
 
-func newScoreboardModel() *scoreboardModel {
+func newScoreboardModel(user *db.User) *scoreboardModel {
 	sm := &scoreboardModel{
+		user:  user,
 		teams: []scoreboardTeam{}, // Will be populated when needed
 	}
 	sm.loadScoreboard()
@@ -29,7 +35,12 @@ func newScoreboardModel() *scoreboardModel {
 }
 
 func (sm *scoreboardModel) loadScoreboard() {
-	dbTeams, err := db.GetScoreboard()
+	sm.frozen = db.ScoreboardFrozen()
+	getScoreboard := db.GetScoreboard
+	if sm.user.IsAdmin() {
+		getScoreboard = db.GetLiveScoreboard
+	}
+	dbTeams, err := getScoreboard()
 	if err != nil {
 		// If there's an error, just use empty list
 		sm.teams = []scoreboardTeam{}
@@ -43,9 +54,25 @@ func (sm *scoreboardModel) loadScoreboard() {
 		})
 	}
 	sm.teams = teams
+	if sm.showGraph {
+		sm.loadTimelines()
+	}
+}
+
+func (sm *scoreboardModel) loadTimelines() {
+	getTimelines := db.GetScoreTimelines
+	if sm.user.IsAdmin() {
+		getTimelines = db.GetLiveScoreTimelines
+	}
+	timelines, err := getTimelines()
+	if err != nil {
+		log.Printf("Failed to load score timelines: %v\n", err)
+		return
+	}
+	sm.timelines = timelines
 }
 
-func (sm *scoreboardModel) update(msg tea.KeyMsg) {
+func (sm *scoreboardModel) update(msg tea.KeyMsg) tea.Cmd {
 	if sm.searchMode {
 		switch msg.Type {
 		case tea.KeyRunes, tea.KeySpace:
@@ -71,14 +98,25 @@ func (sm *scoreboardModel) update(msg tea.KeyMsg) {
 				sm.cursor++
 			}
 		}
-		return
+		return nil
 	}
 
 	switch {
-	case key.Matches(msg, key.NewBinding(key.WithKeys("/"))):
+	case !sm.showGraph && key.Matches(msg, key.NewBinding(key.WithKeys("/"))):
 		sm.searchMode = true
 		sm.search = ""
 		sm.cursor = 0
+	case !sm.showGraph && key.Matches(msg, keys.Select):
+		filtered := sm.filteredScoreboard()
+		if sm.cursor < len(filtered) {
+			team := filtered[sm.cursor]
+			return func() tea.Msg { return viewTeamDetailMsg{team} }
+		}
+	case key.Matches(msg, keys.Tab):
+		sm.showGraph = !sm.showGraph
+		if sm.showGraph {
+			sm.loadTimelines()
+		}
 	case key.Matches(msg, keys.Up):
 		if sm.cursor > 0 {
 			sm.cursor--
@@ -89,6 +127,7 @@ func (sm *scoreboardModel) update(msg tea.KeyMsg) {
 			sm.cursor++
 		}
 	}
+	return nil
 }
 
 func (sm *scoreboardModel) filteredScoreboard() []scoreboardTeam {
@@ -33,6 +33,14 @@ var (
 	successStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("46"))
 
+	bloodStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("160")).
+			Bold(true)
+
+	toastStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("220")).
+			Bold(true)
+
 	windowStyle = lipgloss.NewStyle().
 			Border(lipgloss.RoundedBorder()).
 			BorderForeground(lipgloss.Color("63")).
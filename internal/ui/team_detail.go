@@ -0,0 +1,162 @@
+package ui
+
+import (
+	"log"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"ctfsh/internal/db"
+)
+
+// categoryScore sums a team's solves in one category
+type categoryScore struct {
+	name   string
+	solves int
+	points int
+}
+
+// rankPoint is the place a team took on the scoreboard at a point in time
+type rankPoint struct {
+	time time.Time
+	rank int
+}
+
+// teamDetailModel handles the detail view of a scoreboard entry, a team or a solo player
+type teamDetailModel struct {
+	user       *db.User
+	team       scoreboardTeam
+	members    []string
+	solves     []db.Solve
+	categories []categoryScore
+	ranks      []rankPoint
+	offset     int // index of the first solve shown
+}
+
+// Custom messages for the team detail view
+type viewTeamDetailMsg struct {
+	team scoreboardTeam
+}
+
+func newTeamDetailModel(user *db.User) *teamDetailModel {
+	return &teamDetailModel{user: user}
+}
+
+// load fills in the details of team, ranking it among the entries of board
+func (tdm *teamDetailModel) load(team scoreboardTeam, board []scoreboardTeam) {
+	tdm.team = team
+	tdm.offset = 0
+	tdm.members = nil
+	tdm.solves = nil
+	tdm.categories = nil
+	tdm.ranks = nil
+
+	if team.ID < 0 {
+		tdm.members = []string{team.Name}
+	} else if members, err := db.GetTeamMembers(team.ID); err == nil {
+		for _, member := range members {
+			tdm.members = append(tdm.members, member.Username)
+		}
+		slices.Sort(tdm.members)
+	}
+
+	getSolves, getTimelines := db.GetSolves, db.GetScoreTimelines
+	if tdm.user.IsAdmin() {
+		getSolves, getTimelines = db.GetLiveSolves, db.GetLiveScoreTimelines
+	}
+	solves, err := getSolves(team.ID)
+	if err != nil {
+		log.Printf("Failed to load solves of %s: %v\n", team.Name, err)
+	}
+	tdm.solves = solves
+
+	byCategory := make(map[string]*categoryScore)
+	for _, solve := range solves {
+		cs, ok := byCategory[solve.Category]
+		if !ok {
+			cs = &categoryScore{name: solve.Category}
+			byCategory[solve.Category] = cs
+		}
+		cs.solves++
+		cs.points += solve.Points
+	}
+	for _, cs := range byCategory {
+		tdm.categories = append(tdm.categories, *cs)
+	}
+	sort.Slice(tdm.categories, func(i, j int) bool {
+		if tdm.categories[i].points != tdm.categories[j].points {
+			return tdm.categories[i].points > tdm.categories[j].points
+		}
+		return tdm.categories[i].name < tdm.categories[j].name
+	})
+
+	timelines, err := getTimelines()
+	if err != nil {
+		log.Printf("Failed to load score timelines: %v\n", err)
+		return
+	}
+	ids := make([]int, 0, len(board))
+	for _, t := range board {
+		ids = append(ids, t.ID)
+	}
+	tdm.ranks = rankHistory(timelines, ids, team.ID)
+}
+
+// rankHistory replays the timelines of the entries in ids and returns each change of id's rank
+func rankHistory(timelines map[int][]db.ScorePoint, ids []int, id int) []rankPoint {
+	own := timelines[id]
+	if len(own) == 0 {
+		return nil
+	}
+	var times []time.Time
+	for _, other := range ids {
+		for _, p := range timelines[other] {
+			if !p.Time.Before(own[0].Time) {
+				times = append(times, p.Time)
+			}
+		}
+	}
+	slices.SortFunc(times, func(a, b time.Time) int { return a.Compare(b) })
+	times = slices.Compact(times)
+
+	// Like the scoreboard, rank teams before solo players
+	teams := 0
+	for _, other := range ids {
+		if other >= 0 {
+			teams++
+		}
+	}
+	var ranks []rankPoint
+	for _, t := range times {
+		score := scoreAt(own, t)
+		rank := 1
+		if id < 0 {
+			rank += teams
+		}
+		for _, other := range ids {
+			if other != id && (other < 0) == (id < 0) && scoreAt(timelines[other], t) > score {
+				rank++
+			}
+		}
+		if len(ranks) == 0 || ranks[len(ranks)-1].rank != rank {
+			ranks = append(ranks, rankPoint{t, rank})
+		}
+	}
+	return ranks
+}
+
+func (tdm *teamDetailModel) update(msg tea.KeyMsg) {
+	switch {
+	case key.Matches(msg, keys.Up):
+		if tdm.offset > 0 {
+			tdm.offset--
+		}
+	case key.Matches(msg, keys.Down):
+		if tdm.offset < len(tdm.solves)-1 {
+			tdm.offset++
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"log"
 	"sort"
 
 	"ctfsh/internal/db"
@@ -42,13 +43,16 @@ func (tmm *teamMembersModel) loadTeamMembers() {
 		return
 	}
 
-	// Calculate points for each member
+	points, err := db.GetTeamMemberPoints(*tmm.user.TeamID)
+	if err != nil {
+		log.Printf("Failed to load team member points: %v\n", err)
+	}
+
 	var teamMembers []teamMember
 	for _, member := range members {
-		points := tmm.calculateMemberPoints(member.ID)
 		teamMembers = append(teamMembers, teamMember{
 			User:   member,
-			Points: points,
+			Points: points[member.ID],
 		})
 	}
 
@@ -60,25 +64,6 @@ func (tmm *teamMembersModel) loadTeamMembers() {
 	tmm.members = teamMembers
 }
 
-func (tmm *teamMembersModel) calculateMemberPoints(userID int) int {
-	// Get solved challenges for this user
-	solvedMap, err := db.GetChallengesSolvedByUser(userID)
-	if err != nil {
-		return 0
-	}
-
-	// Calculate total points
-	totalPoints := 0
-	challenges := db.GetChallenges()
-	for _, challenge := range challenges {
-		if solvedMap[challenge.ID] {
-			totalPoints += challenge.Points
-		}
-	}
-
-	return totalPoints
-}
-
 func (tmm *teamMembersModel) update(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch {
 	case key.Matches(msg, keys.Up):
@@ -3,8 +3,11 @@ package ui
 import (
 	"fmt"
 	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 
-	"ctfsh/internal/config"
 	"ctfsh/internal/db"
 )
 
@@ -27,9 +30,12 @@ func (m model) renderMenuView() string {
 		userInfo = fmt.Sprintf("User: %s | No team", m.user.Username)
 	}
 
-	options := []string{"Challenges", "Scoreboard", "Team Management"}
+	if status := m.eventStatus(); status != "" {
+		userInfo += "\n" + status
+	}
+
 	var menu strings.Builder
-	for i, option := range options {
+	for i, option := range m.menuOptions() {
 		cursor := "  "
 		if i == m.menuCursor {
 			cursor = selectedStyle.Render("> ")
@@ -46,6 +52,38 @@ func (m model) renderMenuView() string {
 	return fmt.Sprintf("%s\n\n%s\n\n%s%s", title, userInfo, menu.String(), help)
 }
 
+// eventStatus counts down to the start or end of the CTF and to the next challenge release
+func (m model) eventStatus() string {
+	now := time.Now()
+	start, end := m.cfg.EventStart, m.cfg.EventEnd
+	var lines []string
+	switch {
+	case !start.IsZero() && now.Before(start):
+		lines = append(lines, categoryStyle.Render("CTF starts in "+formatCountdown(start.Sub(now))))
+	case !end.IsZero() && now.Before(end):
+		lines = append(lines, categoryStyle.Render("CTF ends in "+formatCountdown(end.Sub(now))))
+	case !end.IsZero():
+		lines = append(lines, errorStyle.Render("CTF is over, flags are no longer accepted"))
+	}
+	next := m.challenges.nextRelease
+	if !next.IsZero() && next.After(now) && (start.IsZero() || !now.Before(start)) && (end.IsZero() || next.Before(end)) {
+		lines = append(lines, helpStyle.Render("Next challenge release in "+formatCountdown(next.Sub(now))))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatCountdown formats a duration as [Nd ]hh:mm:ss
+func formatCountdown(d time.Duration) string {
+	d = d.Round(time.Second)
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	clock := fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+	if days > 0 {
+		return fmt.Sprintf("%dd %s", days, clock)
+	}
+	return clock
+}
+
 func (m model) renderChallengeView() string {
 	title := titleStyle.Render("Challenges")
 	renderList := m.challenges.buildChallengeRenderList()
@@ -80,7 +118,14 @@ func (m model) renderChallengeView() string {
 					}
 				}
 			}
-			content.WriteString(fmt.Sprintf("  %s%s (%d pts)%s\n", cursor, v.Name, v.Points, status))
+			if _, ok := m.challenges.blood(v.ID); ok {
+				status += bloodStyle.Render(" 🩸")
+			}
+			if v.locked {
+				content.WriteString(fmt.Sprintf("  %s%s\n", cursor, helpStyle.Render(fmt.Sprintf("🔒 %s (%d pts)", v.Name, v.Value))))
+				continue
+			}
+			content.WriteString(fmt.Sprintf("  %s%s (%d pts) %s%s\n", cursor, v.Name, v.Value, helpStyle.Render(solveCount(v.Solves)), status))
 		}
 	}
 
@@ -101,6 +146,17 @@ func (m model) renderChallengeDetailView() string {
 	}
 	title := titleStyle.Render(titleStr)
 
+	if ch.locked {
+		var missing []string
+		if m.challenges.progress != nil {
+			missing = m.challenges.progress.MissingRequirements(ch.Challenge)
+		}
+		details := fmt.Sprintf("%s - %d pts\n\n🔒 Locked. Solve these first: %s\n",
+			categoryStyle.Render(ch.Category), ch.Value, strings.Join(missing, ", "))
+		help := "\n" + helpStyle.Render("q/Esc: back")
+		return fmt.Sprintf("%s\n\n%s\n%s", title, details, help)
+	}
+
 	status := "Unsolved"
 	if ch.solved {
 		status = successStyle.Render("✓ Solved")
@@ -115,66 +171,180 @@ func (m model) renderChallengeDetailView() string {
 	details := fmt.Sprintf(
 		"%s - %d pts",
 		categoryStyle.Render(ch.Category),
-		ch.Points,
+		ch.Value,
 	)
+	if ch.Dynamic() {
+		details += authorStyle.Render(fmt.Sprintf(" (from %d, min %d, %d solves)", ch.Points, ch.MinimumPoints, ch.Solves))
+	}
 	if ch.solved {
-		details += successStyle.Render(" ✓ Solved")
-		// Show solver if on a team
-		if m.challenges.teamSolvers != nil {
-			if solver, ok := m.challenges.teamSolvers[ch.ID]; ok {
-				details += successStyle.Render(fmt.Sprintf(" by %s", solver))
-			}
+		// Tell teammates who already got the flag for the team
+		if solver, ok := m.challenges.teamSolvers[ch.ID]; ok && solver != m.user.Username {
+			details += successStyle.Render(fmt.Sprintf(" ✓ Already solved by %s", solver))
+		} else {
+			details += successStyle.Render(" ✓ Solved")
 		}
 	}
 	details += fmt.Sprintf("\n\n%s\n", ch.Description)
 
+	if bloods := m.challenges.bloods[ch.ID]; len(bloods) > 0 {
+		details += "\n"
+		for _, blood := range bloods {
+			solver := blood.Username
+			if blood.TeamName != "" {
+				solver += fmt.Sprintf(" (%s)", blood.TeamName)
+			}
+			line := fmt.Sprintf("🩸 %s blood: %s", blood.Ordinal(), solver)
+			if blood.Points > 0 {
+				line += fmt.Sprintf(" +%d", blood.Points)
+			}
+			if blood.By(m.challenges.user) {
+				line = bloodStyle.Render(line)
+			}
+			details += line + "\n"
+		}
+	}
+
+	details += "\n" + m.renderSolvers()
+
 	if len(ch.Downloads) > 0 {
 		scpCmd := "scp"
-		if config.Port != 22 {
-			scpCmd += fmt.Sprintf(" -P %d", config.Port)
+		if m.cfg.Port != 22 {
+			scpCmd += fmt.Sprintf(" -P %d", m.cfg.Port)
 		}
-		scpCmd += fmt.Sprintf(" -r %s:%s .", config.Host, ch.Name)
+		scpCmd += fmt.Sprintf(" -r %s:%s .", m.cfg.Host, ch.Name)
 		details += fmt.Sprintf("\nDownload: %s", commandStyle.Render(scpCmd))
 	}
 
 	if len(ch.Ports) > 0 {
 		tunnelCmd := "ssh"
-		if config.Port != 22 {
-			tunnelCmd += fmt.Sprintf(" -p %d", config.Port)
+		if m.cfg.Port != 22 {
+			tunnelCmd += fmt.Sprintf(" -p %d", m.cfg.Port)
 		}
 		for _, port := range ch.Ports {
 			tunnelCmd += fmt.Sprintf(" -L %d:%s:%d", port, ch.Name, port)
 		}
-		tunnelCmd += fmt.Sprintf(" %s@%s", ch.Name, config.Host)
+		tunnelCmd += fmt.Sprintf(" %s@%s", ch.Name, m.cfg.Host)
 		details += fmt.Sprintf("\nInstance: %s", commandStyle.Render(tunnelCmd))
 	}
 
+	if len(ch.Hints) > 0 {
+		details += fmt.Sprintf("\nHints: %d available, press 'h' to view", len(ch.Hints))
+	}
+
 	help := ""
 	if !ch.solved {
 		if m.showHelp {
-			help = "\n" + helpStyle.Render("Enter/Space: submit flag  q/Esc: back  ?: toggle help")
+			help = "\n" + helpStyle.Render("Enter/Space: submit flag  h: hints  q/Esc: back  ?: toggle help")
 		} else {
 			help = "\n" + helpStyle.Render("Press Enter to submit flag or '?' for help.")
 		}
+	} else if m.showHelp {
+		help = "\n" + helpStyle.Render("h: hints  q/Esc: back  ?: toggle help")
 	}
 	return fmt.Sprintf("%s\n\n%s\n%s", title, details, help)
 }
 
+// solveCount formats how many teams solved a challenge
+func solveCount(n int) string {
+	if n == 1 {
+		return "1 solve"
+	}
+	return fmt.Sprintf("%d solves", n)
+}
+
+// renderSolvers lists the first teams to solve the selected challenge
+func (m model) renderSolvers() string {
+	solvers := m.challenges.solvers
+	if len(solvers) == 0 {
+		return helpStyle.Render("No solves yet, be the first!") + "\n"
+	}
+	var b strings.Builder
+	b.WriteString(categoryStyle.Render(fmt.Sprintf("Solves (%d)", len(solvers))) + "\n")
+	for i, solver := range solvers[:min(len(solvers), 10)] {
+		name := solver.Name
+		if solver.ID > 0 {
+			name += helpStyle.Render(" (" + solver.Username + ")")
+		}
+		b.WriteString(fmt.Sprintf("%3d. %s %s\n", i+1, helpStyle.Render(solver.Timestamp.Local().Format("Jan 2 15:04")), name))
+	}
+	if len(solvers) > 10 {
+		b.WriteString(helpStyle.Render(fmt.Sprintf("     and %d more", len(solvers)-10)) + "\n")
+	}
+	return b.String()
+}
+
+func (m model) renderHintsView() string {
+	title := titleStyle.Render(fmt.Sprintf("Hints - %s", m.challenges.selectedChal.Name))
+
+	var content strings.Builder
+	for i, hint := range m.challenges.hints {
+		cursor := "  "
+		if i == m.challenges.hintCursor {
+			cursor = selectedStyle.Render("> ")
+		}
+		cost := "free"
+		if hint.Cost > 0 {
+			cost = fmt.Sprintf("%d pts", hint.Cost)
+		}
+		if hint.Unlocked {
+			content.WriteString(fmt.Sprintf("%sHint %d %s\n", cursor, hint.Position, helpStyle.Render("("+cost+")")))
+			content.WriteString(fmt.Sprintf("    %s\n\n", hint.Content))
+		} else {
+			content.WriteString(fmt.Sprintf("%sHint %d 🔒 %s\n\n", cursor, hint.Position, helpStyle.Render("("+cost+")")))
+		}
+	}
+
+	message := ""
+	if m.message != "" {
+		style := successStyle
+		if m.messageType == "error" {
+			style = errorStyle
+		}
+		message = style.Render(m.message) + "\n"
+	}
+
+	help := ""
+	if m.showHelp {
+		help = "\n" + helpStyle.Render("↑/↓: move  Enter/Space: unlock  q/Esc: back  ?: toggle help")
+	} else {
+		help = "\n" + helpStyle.Render("Press Enter to unlock a hint or '?' for help.")
+	}
+	return fmt.Sprintf("%s\n\n%s%s%s", title, content.String(), message, help)
+}
+
+func (m model) renderConfirmHintView() string {
+	hint, _ := m.challenges.selectedHint()
+	if hint.Cost > 0 {
+		return fmt.Sprintf("Unlock hint %d for %d points?\nThe cost is deducted from your team's score.\n(y/n)", hint.Position, hint.Cost)
+	}
+	return fmt.Sprintf("Unlock hint %d? (y/n)", hint.Position)
+}
+
 func (m model) renderScoreboardView() string {
+	if m.scoreboard.showGraph {
+		return m.renderScoreGraphView()
+	}
 	title := titleStyle.Render("Scoreboard")
 
 	filtered := m.scoreboard.filteredScoreboard()
 	var b strings.Builder
 	// Always show title and search bar
 	b.WriteString(title + "\n\n")
+	if m.scoreboard.frozen {
+		if m.user.IsAdmin() {
+			b.WriteString(categoryStyle.Render("❄ Frozen for players, showing live standings") + "\n")
+		} else {
+			b.WriteString(categoryStyle.Render(fmt.Sprintf("❄ Scoreboard frozen since %s", m.cfg.FreezeAt.Local().Format("Jan 2 15:04 MST"))) + "\n")
+		}
+	}
 	if m.scoreboard.searchMode {
 		b.WriteString("Search: " + m.scoreboard.search + "\n")
 	} else {
 		b.WriteString("Press '/' to search\n")
 	}
 	// Always show header
-	b.WriteString(fmt.Sprintf("%-4s %-20s %-8s %s\n", "Rank", "Team", "Players", "Score"))
-	b.WriteString(strings.Repeat("─", 45) + "\n")
+	b.WriteString(fmt.Sprintf("%-4s %-20s %-8s %-7s %s\n", "Rank", "Team", "Players", "Score", "Last change"))
+	b.WriteString(strings.Repeat("─", 60) + "\n")
 
 	// Show up to 20 rows, or as many as fit on the screen
 	windowSize := min(len(m.scoreboard.teams), 20)
@@ -209,7 +379,12 @@ func (m model) renderScoreboardView() string {
 			if i == m.scoreboard.cursor {
 				cursor = selectedStyle.Render("> ")
 			}
-			b.WriteString(fmt.Sprintf("%s%-4d %s%s %-8d %d\n", cursor, team.place, teamName, strings.Repeat(" ", paddingLen), team.PlayerCount, team.Score))
+			// Ties are broken by whose score last changed first, so show when that was
+			lastChange := "-"
+			if !team.LastChange.IsZero() {
+				lastChange = team.LastChange.Local().Format("Jan 2 15:04:05")
+			}
+			b.WriteString(fmt.Sprintf("%s%-4d %s%s %-8d %-7d %s\n", cursor, team.place, teamName, strings.Repeat(" ", paddingLen), team.PlayerCount, team.Score, helpStyle.Render(lastChange)))
 			teamRows++
 		} else {
 			break
@@ -222,13 +397,120 @@ func (m model) renderScoreboardView() string {
 
 	help := ""
 	if m.showHelp {
-		help = "\n" + helpStyle.Render("↑/↓: scroll  /: search  q/Esc: back  ?: toggle help")
+		help = "\n" + helpStyle.Render("↑/↓: scroll  enter: details  /: search  tab: graph  q/Esc: back  ?: toggle help")
+	} else {
+		help = "\n" + helpStyle.Render("Press '?' for help.")
+	}
+	return b.String() + help
+}
+
+// renderScoreGraphView charts the score of the top teams over time
+func (m model) renderScoreGraphView() string {
+	title := titleStyle.Render("Scoreboard - Top Teams")
+
+	var b strings.Builder
+	b.WriteString(title + "\n\n")
+
+	var series []graphSeries
+	var scores []int
+	var start time.Time
+	for _, team := range m.scoreboard.teams[:min(graphTeams, len(m.scoreboard.teams))] {
+		points := m.scoreboard.timelines[team.ID]
+		if len(points) == 0 {
+			continue
+		}
+		if start.IsZero() || points[0].Time.Before(start) {
+			start = points[0].Time
+		}
+		series = append(series, graphSeries{name: team.Name, points: points, color: graphColors[len(series)]})
+		scores = append(scores, team.Score)
+	}
+
+	if len(series) == 0 {
+		b.WriteString(helpStyle.Render("No points scored yet.") + "\n")
+	} else {
+		if !m.cfg.EventStart.IsZero() && m.cfg.EventStart.Before(start) {
+			start = m.cfg.EventStart
+		}
+		end := time.Now()
+		if !m.cfg.EventEnd.IsZero() && m.cfg.EventEnd.Before(end) {
+			end = m.cfg.EventEnd
+		}
+		if m.scoreboard.frozen && !m.user.IsAdmin() {
+			end = m.cfg.FreezeAt
+		}
+		if !end.After(start) {
+			end = start.Add(time.Minute)
+		}
+		width := graphWindowWidth(m.width) - 8
+		height := min(max(m.height-26, 6), 20)
+		b.WriteString(renderScoreGraph(series, start, end, width, height) + "\n")
+		b.WriteString(renderGraphLegend(series, scores, width) + "\n")
+	}
+
+	help := ""
+	if m.showHelp {
+		help = "\n" + helpStyle.Render("tab: table  q/Esc: back  ?: toggle help")
+	} else {
+		help = "\n" + helpStyle.Render("Press '?' for help.")
+	}
+	return b.String() + help
+}
+
+func (m model) renderTeamDetailView() string {
+	td := m.teamDetail
+	title := titleStyle.Render(td.team.Name)
+	if td.team.ID < 0 {
+		title += " " + helpStyle.Render("(solo)")
+	}
+
+	var b strings.Builder
+	b.WriteString(title + "\n\n")
+	b.WriteString(fmt.Sprintf("Rank #%d with %d points\n", td.team.place, td.team.Score))
+	b.WriteString("Members: " + strings.Join(td.members, ", ") + "\n")
+
+	if len(td.ranks) > 0 {
+		// Show the latest rank changes, oldest first
+		var history []string
+		for _, r := range td.ranks[max(len(td.ranks)-6, 0):] {
+			history = append(history, fmt.Sprintf("#%d %s", r.rank, helpStyle.Render(r.time.Local().Format("15:04"))))
+		}
+		b.WriteString("Rank history: " + strings.Join(history, " → ") + "\n")
+	}
+
+	if len(td.categories) > 0 {
+		b.WriteString("\n" + categoryStyle.Render("Categories") + "\n")
+		for _, cs := range td.categories {
+			b.WriteString(fmt.Sprintf("  %-16s %2d solved  %5d pts\n", cs.name, cs.solves, cs.points))
+		}
+	}
+
+	b.WriteString("\n" + categoryStyle.Render("Solves") + "\n")
+	if len(td.solves) == 0 {
+		b.WriteString(helpStyle.Render("  No solves yet.") + "\n")
+	} else {
+		b.WriteString(fmt.Sprintf("  %-12s %-18s %-12s %-12s %s\n", "Time", "Challenge", "Category", "Solved by", "Points"))
+		// Show up to 10 solves, scrolling from offset
+		end := min(td.offset+10, len(td.solves))
+		for _, solve := range td.solves[td.offset:end] {
+			b.WriteString(fmt.Sprintf("  %-12s %-18s %-12s %-12s %d\n", solve.Timestamp.Local().Format("Jan 2 15:04"), solve.Challenge, solve.Category, solve.Username, solve.Points))
+		}
+	}
+
+	help := ""
+	if m.showHelp {
+		help = "\n" + helpStyle.Render("↑/↓: scroll solves  q/Esc: back  ?: toggle help")
 	} else {
 		help = "\n" + helpStyle.Render("Press '?' for help.")
 	}
 	return b.String() + help
 }
 
+// graphWindowWidth is the window width for the score graph, which uses most of the terminal
+func graphWindowWidth(termWidth int) int {
+	return max(termWidth-8, 40)
+}
+
 func (m model) renderTeamView() string {
 	title := titleStyle.Render("Team Management")
 
@@ -242,10 +524,10 @@ func (m model) renderTeamView() string {
 		joinCode := m.team.teamJoinCode
 		sshCmd := ""
 		if joinCode != "" {
-			if config.Port == 22 {
-				sshCmd = fmt.Sprintf("ssh %s@%s", joinCode, config.Host)
+			if m.cfg.Port == 22 {
+				sshCmd = fmt.Sprintf("ssh %s@%s", joinCode, m.cfg.Host)
 			} else {
-				sshCmd = fmt.Sprintf("ssh %s@%s -p %d", joinCode, config.Host, config.Port)
+				sshCmd = fmt.Sprintf("ssh %s@%s -p %d", joinCode, m.cfg.Host, m.cfg.Port)
 			}
 		}
 		options := []string{"Leave Team", "Regenerate Join Code", "View Team Members"}
@@ -307,6 +589,11 @@ func (m model) renderGenericInputView() string {
 		}
 		message = "\n" + style.Render(m.message)
 	}
+	if m.onBackState == challengeDetailView {
+		if left := time.Until(m.challenges.lockedUntil); left > 0 {
+			message += "\n" + errorStyle.Render("🔒 Locked out, try again in "+formatCountdown(left))
+		}
+	}
 
 	help := ""
 	if m.showHelp {
@@ -378,3 +665,182 @@ func (m model) renderTeamMembersView() string {
 	}
 	return content.String() + help
 }
+
+func (m model) renderAdminView() string {
+	title := titleStyle.Render("Admin")
+
+	var menu strings.Builder
+	for i, section := range adminSections {
+		cursor := "  "
+		if i == m.admin.menuCursor {
+			cursor = selectedStyle.Render("> ")
+		}
+		menu.WriteString(cursor + section + "\n")
+	}
+
+	help := ""
+	if m.showHelp {
+		help = "\n" + helpStyle.Render("↑/↓: move  Enter/Space: select  q/Esc: back  ?: toggle help")
+	} else {
+		help = "\n" + helpStyle.Render("Press '?' for help.")
+	}
+	return fmt.Sprintf("%s\n\n%s%s", title, menu.String(), help)
+}
+
+// adminRows renders the header and one line per row of the current admin section
+func (m model) adminRows() (string, []string) {
+	am := m.admin
+	var header string
+	var rows []string
+	switch am.section {
+	case adminSubmissions:
+		header = fmt.Sprintf("%-8s %-16s %-20s %s", "Time", "User", "Challenge", "Flag")
+		for _, sub := range am.submissions {
+			mark := errorStyle.Render("✗")
+			if sub.Correct {
+				mark = successStyle.Render("✓")
+			}
+			rows = append(rows, fmt.Sprintf("%-8s %-16s %-20s %s %s", sub.Timestamp.Local().Format(time.TimeOnly), sub.Username, sub.Challenge, mark, truncate(sub.Flag, 40)))
+		}
+	case adminTeams:
+		header = fmt.Sprintf("%-20s %-8s %-8s %s", "Team", "Players", "Score", "Status")
+		for _, team := range am.teams {
+			name := team.Name
+			if team.ID < 0 {
+				name += " (solo)"
+			}
+			rows = append(rows, fmt.Sprintf("%-20s %-8d %-8d %s", name, team.PlayerCount, team.Score, bannedStatus(team.Banned)))
+		}
+	case adminPlayers:
+		header = fmt.Sprintf("%-20s %-20s %s", "Username", "Team", "Status")
+		for _, player := range am.players {
+			status := bannedStatus(player.Banned)
+			if !player.Banned && player.TeamBanned {
+				status = errorStyle.Render("team banned")
+			}
+			rows = append(rows, fmt.Sprintf("%-20s %-20s %s", player.Username, player.TeamName, status))
+		}
+	case adminChallenges:
+		header = fmt.Sprintf("%-14s %-24s %-8s %s", "Category", "Name", "Points", "Status")
+		for _, chal := range am.challenges {
+			status := successStyle.Render("visible")
+			switch {
+			case chal.Hidden:
+				status = helpStyle.Render("removed from disk")
+			case chal.Disabled:
+				status = errorStyle.Render("hidden")
+			case !chal.Released():
+				status = helpStyle.Render("not released")
+			}
+			rows = append(rows, fmt.Sprintf("%-14s %-24s %-8d %s", chal.Category, chal.Name, chal.Points, status))
+		}
+	case adminInstances:
+		header = fmt.Sprintf("%-22s %-16s %-14s %s", "Instance", "Challenge", "User", "Uptime")
+		for _, inst := range am.instances {
+			username := inst.Username
+			if username == "" {
+				username = "-"
+			}
+			uptime := "-"
+			if !inst.Started.IsZero() {
+				uptime = formatCountdown(time.Since(inst.Started))
+			}
+			rows = append(rows, fmt.Sprintf("%-22s %-16s %-14s %s", inst.Name, inst.Challenge, username, uptime))
+		}
+	case adminCheats:
+		header = fmt.Sprintf("%-14s %-16s %-20s %s", "Time", "User", "Challenge", "Flag of")
+		for _, event := range am.cheats {
+			rows = append(rows, fmt.Sprintf("%-14s %-16s %-20s %s", event.Timestamp.Local().Format("Jan 2 15:04"), event.Username, event.Challenge, event.Owner))
+		}
+	}
+	return header, rows
+}
+
+func bannedStatus(banned bool) string {
+	if banned {
+		return errorStyle.Render("banned")
+	}
+	return ""
+}
+
+// truncate shortens s to at most n columns, ending it with an ellipsis when cut
+func truncate(s string, n int) string {
+	return ansi.Truncate(s, n, "…")
+}
+
+func (m model) renderAdminSectionView() string {
+	title := titleStyle.Render("Admin - " + adminSections[m.admin.section])
+
+	var b strings.Builder
+	b.WriteString(title + "\n\n")
+	if m.admin.loadErr != nil {
+		b.WriteString(errorStyle.Render("Failed to load: "+m.admin.loadErr.Error()) + "\n")
+	}
+
+	header, rows := m.adminRows()
+	b.WriteString("  " + header + "\n")
+	b.WriteString(strings.Repeat("─", lipgloss.Width(header)+2) + "\n")
+	if len(rows) == 0 {
+		b.WriteString(helpStyle.Render("  (nothing here yet)") + "\n")
+	}
+
+	// Show up to 15 rows, scrolling to keep the cursor visible
+	windowSize := 15
+	start := max(m.admin.cursor-windowSize+1, 0)
+	end := min(start+windowSize, len(rows))
+	for i := start; i < end; i++ {
+		cursor := "  "
+		if i == m.admin.cursor {
+			cursor = selectedStyle.Render("> ")
+		}
+		b.WriteString(cursor + rows[i] + "\n")
+	}
+
+	if m.message != "" {
+		style := successStyle
+		if m.messageType == "error" {
+			style = errorStyle
+		}
+		b.WriteString("\n" + style.Render(m.message) + "\n")
+	}
+
+	actions := map[adminSection]string{
+		adminSubmissions: "r: refresh",
+		adminTeams:       "b: ban/unban  s: adjust score  r: refresh",
+		adminPlayers:     "b: ban/unban  r: refresh",
+		adminChallenges:  "v: show/hide  r: reload from disk",
+		adminInstances:   "x: stop instance  r: refresh",
+		adminCheats:      "r: refresh",
+	}
+	help := ""
+	if m.showHelp {
+		help = "\n" + helpStyle.Render("↑/↓: scroll  "+actions[m.admin.section]+"  q/Esc: back  ?: toggle help")
+	} else {
+		help = "\n" + helpStyle.Render("Press '?' for help.")
+	}
+	return b.String() + help
+}
+
+func (m model) renderAnnouncementsView() string {
+	title := titleStyle.Render("Announcements")
+
+	var b strings.Builder
+	b.WriteString(title + "\n\n")
+	if len(m.announcements.items) == 0 {
+		b.WriteString(helpStyle.Render("No announcements yet.") + "\n")
+	}
+	// Show up to 8 announcements, newest first
+	end := min(m.announcements.offset+8, len(m.announcements.items))
+	for _, a := range m.announcements.items[m.announcements.offset:end] {
+		b.WriteString(categoryStyle.Render(a.Timestamp.Local().Format("Jan 2 15:04")) + " " + authorStyle.Render(a.Author) + "\n")
+		b.WriteString(a.Message + "\n\n")
+	}
+
+	help := ""
+	if m.showHelp {
+		help = "\n" + helpStyle.Render("↑/↓: scroll  q/Esc: back  ?: toggle help")
+	} else {
+		help = "\n" + helpStyle.Render("Press '?' for help.")
+	}
+	return b.String() + help
+}